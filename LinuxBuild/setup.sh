@@ -1,27 +1,27 @@
 #!/bin/bash
 
-if [ -n "$1" ]; then
-    case "$1" in
-    "next-js")\
-        apt-get update -y && apt-get install procps -y
-        npx --yes create-next-app@latest . --js --use-npm --tailwind --eslint --app --no-src-dir --no-import-alias 
-        ;;
-    "next-ts")\
-        apt-get update -y && apt-get install procps -y
-        npx --yes create-next-app@latest . --ts --use-npm --tailwind --eslint --app --no-src-dir --no-import-alias
-        ;;
-    "nest")\
-        apt-get update -y && apt-get install procps -y
-        npm install -g @nestjs/cli
-        nest new . -p npm
-        ;;
-    "goftt")\
-        git clone https://github.com/harshau007/GoFTT.git .
-        ;;
-    *)
-        echo "Unknown template: $1"
-        ;;
-    esac
+# The template scaffold comes from the template manifest and only runs the
+# first time the container starts, so restarts never overwrite the project.
+SCAFFOLD_MARKER=/var/lib/contanize/scaffolded
+
+if [ -n "$TEMPLATE_SCAFFOLD" ] && [ ! -f "$SCAFFOLD_MARKER" ]; then
+    echo "Scaffolding template: $1"
+    bash -ec "$TEMPLATE_SCAFFOLD" || {
+        echo "Template $1 failed"
+        exit 1
+    }
+    mkdir -p "$(dirname "$SCAFFOLD_MARKER")"
+    touch "$SCAFFOLD_MARKER"
+fi
+
+# postCreateCommand of a devcontainer.json, also only run once
+POST_CREATE_MARKER=/var/lib/contanize/post-created
+
+if [ -n "$POST_CREATE_COMMAND" ] && [ ! -f "$POST_CREATE_MARKER" ]; then
+    echo "Running postCreateCommand"
+    bash -c "$POST_CREATE_COMMAND" || echo "postCreateCommand failed"
+    mkdir -p "$(dirname "$POST_CREATE_MARKER")"
+    touch "$POST_CREATE_MARKER"
 fi
 
 # Start code-server
@@ -24,7 +24,13 @@ import (
 
 // App struct
 type App struct {
-	ctx context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	events  *services.DockerEvents
+	logs    *services.LogStreamer
+	jobs    *services.JobManager
+	store   *services.Reconciler
+	backups *services.BackupScheduler
 }
 
 type containerDetail struct {
@@ -41,6 +47,9 @@ type containerDetail struct {
 	DBUser      string   `json:"dbuser"`
 	DB          string   `json:"db"`
 	DBPass      string   `json:"dbpass"`
+	DBName      string   `json:"dbname"`
+	// Connection is the connection string of a database container.
+	Connection string `json:"connection"`
 }
 
 type Port struct {
@@ -91,6 +100,20 @@ func NewApp() *App {
 func (a *App) startup(ctx context.Context) {
 	// Perform your setup here
 	a.ctx = ctx
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	a.cancel = cancel
+	a.store = services.NewReconciler(a.emit)
+	a.events = services.NewDockerEvents(func(name string, payload interface{}) {
+		a.emit(name, payload)
+		a.store.Notify(name)
+	})
+	a.logs = services.NewLogStreamer(a.emit)
+	a.jobs = services.NewJobManager(a.emit)
+	a.backups = services.NewBackupScheduler(a.emit)
+	go a.events.Watch(watchCtx)
+	go a.store.Run(watchCtx)
+	go a.backups.Run(watchCtx)
 }
 
 // domReady is called after front-end resources have been loaded
@@ -108,6 +131,20 @@ func (a *App) beforeClose(ctx context.Context) (prevent bool) {
 // shutdown is called at application termination
 func (a *App) shutdown(ctx context.Context) {
 	// Perform your teardown here
+	if a.cancel != nil {
+		a.cancel()
+	}
+	if a.logs != nil {
+		a.logs.StopAll()
+	}
+	if a.jobs != nil {
+		a.jobs.CancelAll()
+	}
+}
+
+// emit publishes a backend event to the frontend
+func (a *App) emit(name string, payload interface{}) {
+	runtime.EventsEmit(a.ctx, name, payload)
 }
 
 // Greet returns a greeting for the given name
@@ -128,6 +165,37 @@ func (a *App) SelectFolder() string {
 	return dir
 }
 
+// StreamLogs emits the stdout and stderr of a container as log events
+// until the stream ends or StopLogStream is called.
+func (a *App) StreamLogs(containerID string, follow bool, tail string, since string) error {
+	return a.logs.Stream(a.ctx, containerID, follow, tail, since)
+}
+
+func (a *App) StopLogStream(containerID string) {
+	a.logs.Stop(containerID)
+}
+
+// ExportLogs saves the container logs between since and until to a file
+// picked by the user and returns its path.
+func (a *App) ExportLogs(containerID string, since string, until string) (string, error) {
+	filename, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Logs",
+		DefaultFilename: containerID + ".log",
+		ShowHiddenFiles: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if filename == "" {
+		return "", nil
+	}
+
+	if err := services.ExportLogs(containerID, since, until, filename); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
 func (a *App) ListAllContainersJSON() []containerDetail {
 	ctx := context.Background()
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
@@ -190,31 +258,21 @@ func (a *App) ListAllContainersJSON() []containerDetail {
 			}
 		}
 
-		isDatabase := false
+		isDatabase := container.Labels["type"] == "Database"
 		DBUser := "none"
-		DB := ""
-		DBPass := ""
-		if container.Labels != nil {
-			for k, v := range container.Labels {
-				// fmt.Printf("key[%s] value[%s]\n", k, v)
-				if strings.Contains(v, "Database") {
-					isDatabase = true
-				}
-
-				if strings.Contains(k, "dbuser") {
-					DBUser = v
-				}
-
-				if strings.Contains(v, "mongo") {
-					DB = "mongo"
-				}
-
-				if strings.Contains(v, "postgres") {
-					DB = "postgres"
-				}
-
-				if strings.Contains(k, "dbpass") {
-					DBPass = v
+		DB := container.Labels["db"]
+		DBPass := container.Labels["dbpass"]
+		DBName := container.Labels["dbname"]
+		connection := ""
+		if user := container.Labels["dbuser"]; user != "" {
+			DBUser = user
+		}
+		if engine, err := services.FindDatabaseEngine(DB); isDatabase && err == nil {
+			for _, port := range container.Ports {
+				if int(port.PrivatePort) == engine.Info().Port && port.PublicPort != 0 {
+					credentials := services.DatabaseCredentialsFromLabels(container.Labels)
+					connection = engine.ConnectionString(credentials, "localhost", int(port.PublicPort))
+					break
 				}
 			}
 		}
@@ -233,6 +291,8 @@ func (a *App) ListAllContainersJSON() []containerDetail {
 			DBUser:      DBUser,
 			DB:          DB,
 			DBPass:      DBPass,
+			DBName:      DBName,
+			Connection:  connection,
 		})
 	}
 	// fmt.Println(containerInfo)
@@ -247,53 +307,260 @@ func (a *App) URL(url string) {
 	}
 }
 
-func (a *App) CreateCodeInstance(name string, packageName string, folder string, ports string, template string) (string, error) {
-	var output []byte
-	if strings.Contains(template, "next-js") {
-		err := services.CreateContainer(strings.ToLower(name), "nodelts", folder, ports, template)
+// CreateCodeInstance starts a job that builds and runs a new workspace and
+// returns the job ID right away. Progress is reported through job events.
+func (a *App) CreateCodeInstance(name string, technology string, version string, folder string, ports string, template string, platform string) (string, error) {
+	var scaffold string
+	if template != "" && template != "none" {
+		manifest, err := services.FindTemplate(template)
 		if err != nil {
-			fmt.Printf("error executing the script: %s", err)
+			return "", err
 		}
-	} else if strings.Contains(template, "next-ts") {
-		err := services.CreateContainer(strings.ToLower(name), "nodelts", folder, ports, template)
-		if err != nil {
-			fmt.Printf("error executing the script: %s", err)
-		}
-	} else if strings.Contains(template, "nest") {
-		err := services.CreateContainer(strings.ToLower(name), "nodelts", folder, ports, template)
-		if err != nil {
-			fmt.Printf("error executing the script: %s", err)
-		}
-	} else if strings.Contains(template, "goftt") {
-		err := services.CreateContainer(strings.ToLower(name), "go", folder, ports, template)
-		if err != nil {
-			fmt.Printf("error executing the script: %s", err)
+		technology = manifest.Technology
+		version = manifest.Version
+		scaffold = manifest.ScaffoldScript()
+		if ports == "" {
+			ports = manifest.DefaultPorts()
 		}
 	} else {
-		err := services.CreateContainer(strings.ToLower(name), packageName, folder, ports, "none")
+		template = "none"
+	}
+
+	// A devcontainer.json in the folder defines the environment instead
+	// of the runtime catalog
+	var devcontainer *services.Devcontainer
+	if path, ok := services.FindDevcontainer(folder); ok {
+		dev, err := services.LoadDevcontainer(path, folder)
 		if err != nil {
-			fmt.Printf("error executing the script: %s", err)
+			return "", err
 		}
+		devcontainer = dev
+		technology, version = "none", ""
+		ports = mergePorts(ports, dev.Ports())
+	}
+	if err := services.ValidatePorts(ports); err != nil {
+		return "", err
+	}
+
+	if version == "" {
+		technology, version = services.ParseTechnology(technology)
+	}
+	runtimeID, version, err := services.ResolveRuntime(technology, version)
+	if err != nil {
+		return "", err
+	}
+
+	opts := services.CreateOptions{
+		Name:           strings.ToLower(name),
+		Runtime:        runtimeID,
+		RuntimeVersion: version,
+		Volume:         folder,
+		Ports:          ports,
+		Template:       template,
+		Scaffold:       scaffold,
+		Platform:       platform,
+		Devcontainer:   devcontainer,
+	}
+	jobID := a.jobs.Start(a.ctx, "create", opts.Name, func(ctx context.Context, jobID string, setPhase func(services.JobPhase)) (string, error) {
+		opts.BuildID = jobID
+		opts.OnPhase = setPhase
+		return services.CreateContainer(ctx, opts, a.emit)
+	})
+
+	return jobID, nil
+}
+
+// RebuildWorkspace rebuilds a workspace on a fresh image as a background
+// job, keeping its folder and ports, and returns the job ID.
+func (a *App) RebuildWorkspace(name string, options services.RebuildOptions) string {
+	return a.jobs.Start(a.ctx, "rebuild", name, func(ctx context.Context, jobID string, setPhase func(services.JobPhase)) (string, error) {
+		return services.RebuildWorkspace(ctx, name, options, jobID, setPhase, a.emit)
+	})
+}
+
+// DetectDevcontainer returns a summary of the devcontainer.json in folder,
+// or nil if there is none.
+func (a *App) DetectDevcontainer(folder string) (*services.DevcontainerInfo, error) {
+	path, ok := services.FindDevcontainer(folder)
+	if !ok {
+		return nil, nil
+	}
+	dev, err := services.LoadDevcontainer(path, folder)
+	if err != nil {
+		return nil, err
+	}
+	info := dev.Info()
+	return &info, nil
+}
+
+// ListTechnologies returns the runtime catalog with the versions that can
+// be selected for a new workspace.
+func (a *App) ListTechnologies() []services.Runtime {
+	return services.ListRuntimes()
+}
+
+// ListBaseImages returns the cached base images shared by workspaces.
+func (a *App) ListBaseImages() ([]services.BaseImage, error) {
+	return services.ListBaseImages()
+}
+
+// ClearBaseImageCache removes the cached base images, they are rebuilt by
+// the next workspace that needs them.
+func (a *App) ClearBaseImageCache() ([]string, error) {
+	return services.ClearBaseImages()
+}
+
+// CollectImages lists the workspace images no workspace uses anymore and,
+// unless dryRun is set, removes them.
+func (a *App) CollectImages(dryRun bool) (*services.ImageGCReport, error) {
+	return services.CollectImages(a.ctx, dryRun)
+}
+
+// CreateSnapshot commits the current state of a workspace to a snapshot
+// image.
+func (a *App) CreateSnapshot(container, note string) (*services.Snapshot, error) {
+	return services.CreateSnapshot(container, note)
+}
+
+// ListSnapshots returns the snapshots of a workspace, oldest first.
+func (a *App) ListSnapshots(container string) ([]services.Snapshot, error) {
+	return services.ListSnapshots(container)
+}
+
+// RestoreSnapshot recreates a workspace from one of its snapshots and
+// returns the snapshot taken of the state it replaced.
+func (a *App) RestoreSnapshot(container, tag string) (*services.Snapshot, error) {
+	return services.RestoreSnapshot(container, tag)
+}
+
+// ExportWorkspace writes a workspace bundle to a file picked by the user,
+// including the workspace folder if includeFiles is set, and returns its
+// path.
+func (a *App) ExportWorkspace(container string, includeFiles bool) (string, error) {
+	filename, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Workspace",
+		DefaultFilename: container + ".contanize.tar.gz",
+		ShowHiddenFiles: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if filename == "" {
+		return "", nil
 	}
-	outputLines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	containerId := outputLines[len(outputLines)-1]
 
-	return containerId, nil
+	if err := services.ExportWorkspace(a.ctx, container, filename, includeFiles); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// ImportWorkspace creates a workspace from a bundle and a folder picked by
+// the user and returns the ID of its container. An empty name keeps the
+// name of the exported workspace.
+func (a *App) ImportWorkspace(name string) (string, error) {
+	filename, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Import Workspace",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Workspace bundles (*.tar.gz)", Pattern: "*.tar.gz"},
+		},
+		ShowHiddenFiles: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if filename == "" {
+		return "", nil
+	}
+
+	folder, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "Select Workspace Folder",
+		ShowHiddenFiles:      true,
+		CanCreateDirectories: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if folder == "" {
+		return "", nil
+	}
+
+	return services.ImportWorkspace(a.ctx, filename, name, folder)
+}
+
+// CloneWorkspace creates a copy of a workspace from its current state and
+// returns the ID of the new container. An empty newFolder shares the folder
+// of the source workspace.
+func (a *App) CloneWorkspace(source, newName, newFolder string, copyFiles bool) (string, error) {
+	return services.CloneWorkspace(a.ctx, source, newName, newFolder, copyFiles)
 }
 
-func (a *App) RemoveContainer(id string, force bool) {
+// ReconcileStore brings the workspace store in line with Docker and
+// reports what it changed.
+func (a *App) ReconcileStore() (*services.ReconcileReport, error) {
+	return a.store.Reconcile(a.ctx)
+}
+
+// ListTemplates returns the project templates available for new workspaces.
+func (a *App) ListTemplates() ([]services.Template, error) {
+	return services.ListTemplates()
+}
+
+func (a *App) GetJob(id string) (services.Job, error) {
+	return a.jobs.Get(id)
+}
+
+func (a *App) ListJobs() []services.Job {
+	return a.jobs.List()
+}
+
+func (a *App) CancelJob(id string) error {
+	return a.jobs.Cancel(id)
+}
+
+// RemoveContainer removes a container together with its store entry. With
+// removeImage set, the workspace image is removed as well once nothing else
+// uses it.
+func (a *App) RemoveContainer(id string, force bool, removeImage bool) {
 	ctx := context.Background()
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		fmt.Println("Error connecting to Docker")
 	}
 
+	var imageRef, database string
+	if info, err := cli.ContainerInspect(ctx, id); err == nil {
+		if removeImage {
+			imageRef = info.Image
+		}
+		if info.Config.Labels["type"] == "Database" {
+			database = strings.TrimPrefix(info.Name, "/")
+		}
+	}
+
 	err = cli.ContainerRemove(ctx, id, containertypes.RemoveOptions{Force: force})
 	if err != nil {
 		fmt.Println("Container must be forced to remove")
 		fmt.Println(err)
+		return
+	}
+	if err := services.ForgetContainer(id); err != nil {
+		fmt.Println(err)
 	}
 	fmt.Println("Container removed: " + id)
+
+	// Backups are kept, only their schedule goes with the database
+	if database != "" {
+		if err := services.RemoveBackupSchedule(ctx, database); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	if imageRef != "" {
+		if err := services.RemoveWorkspaceImage(ctx, imageRef); err != nil {
+			fmt.Println(err)
+		}
+	}
 }
 
 func (a *App) ForceRemoveContainer(id string) {
@@ -306,15 +573,22 @@ func (a *App) ForceRemoveContainer(id string) {
 	if err != nil {
 		fmt.Println("Container must be forced to remove")
 		fmt.Println(err)
+		return
+	}
+	if err := services.ForgetContainer(id); err != nil {
+		fmt.Println(err)
 	}
 	fmt.Println("Container removed: " + id)
 }
 
 func (a *App) StartContainer(name string, port string) string {
-	err := services.StartContainer(name, port)
+	changes, err := services.StartContainer(name, port)
 	if err != nil {
 		fmt.Printf("Error starting container: %v\n", err)
 	}
+	if len(changes) > 0 {
+		a.emit(services.EventPortsChanged, services.PortsChanged{Name: name, Changes: changes})
+	}
 	return "Success"
 }
 
@@ -638,31 +912,106 @@ func (a *App) GetContainerMetrics(containerID string) (ContainerMetrics, error)
 	}, nil
 }
 
-func (a *App) CreateDB(dbtype, username, password, dbname, contname string) string {
-	switch dbtype {
-	case "postgres":
-		id, err := services.RunPostgresContainer(username, password, dbname, contname)
-		if err != nil {
-			log.Fatal("Error occured while creating Postgres Instance", err)
-		}
-		return id
-	case "mongo":
-		id, err := services.RunMongoContainer(username, password, dbname, contname)
-		if err != nil {
-			log.Fatal("Error occured while creating Postgres Instance", err)
-		}
-		return id
-	default:
-		return "No Container Created"
+// CreateDB creates and starts a database container and returns its ID.
+// The username and database name are ignored by engines without them.
+func (a *App) CreateDB(dbtype, username, password, dbname, contname string) (string, error) {
+	return services.CreateDatabase(dbtype, services.DatabaseCredentials{
+		User:     username,
+		Password: password,
+		Database: dbname,
+	}, contname)
+}
+
+// ListDatabaseEngines returns the database engines CreateDB accepts.
+func (a *App) ListDatabaseEngines() []services.DatabaseEngineInfo {
+	return services.ListDatabaseEngines()
+}
+
+// ListVolumes returns the volumes holding the data of databases. They are
+// kept when their database is removed.
+func (a *App) ListVolumes() ([]services.Volume, error) {
+	return services.ListVolumes(a.ctx)
+}
+
+// RemoveVolume removes a database volume that is no longer mounted.
+func (a *App) RemoveVolume(name string) error {
+	return services.RemoveVolume(a.ctx, name)
+}
+
+// DumpDatabase writes an archive of a running database to destPath,
+// emitting database:progress events along the way.
+func (a *App) DumpDatabase(container, destPath string) error {
+	return services.DumpDatabase(a.ctx, container, destPath, a.emit)
+}
+
+// RestoreDatabase loads an archive written by DumpDatabase from srcPath
+// into a running database, emitting database:progress events along the
+// way.
+func (a *App) RestoreDatabase(container, srcPath string) error {
+	return services.RestoreDatabase(a.ctx, container, srcPath, a.emit)
+}
+
+// BackupDatabase backs a running database up right away, keeping as many
+// backups as its schedule retains.
+func (a *App) BackupDatabase(container string) (*services.Backup, error) {
+	retention := 0
+	schedule, err := services.GetBackupSchedule(a.ctx, container)
+	if err != nil {
+		return nil, err
 	}
+	if schedule != nil {
+		retention = schedule.Retention
+	}
+	return services.BackupDatabase(a.ctx, container, retention, a.emit)
+}
+
+// ListBackups returns the backups of a database, newest first.
+func (a *App) ListBackups(container string) ([]services.Backup, error) {
+	return services.ListBackups(a.ctx, container)
+}
+
+// RestoreBackup loads one of the backups of a database back into it.
+func (a *App) RestoreBackup(container, name string) error {
+	return services.RestoreBackup(a.ctx, container, name, a.emit)
+}
+
+// GetBackupSchedule returns the backup schedule of a database, or nil if it
+// has none.
+func (a *App) GetBackupSchedule(container string) (*services.BackupSchedule, error) {
+	return services.GetBackupSchedule(a.ctx, container)
+}
+
+// SetBackupSchedule backs a database up hourly, daily or on a cron
+// schedule, keeping the given number of backups.
+func (a *App) SetBackupSchedule(container, schedule string, retention int) error {
+	return services.SetBackupSchedule(a.ctx, container, schedule, retention)
 }
 
-func (a *App) OpenPostgresTerminal(contName, dbuser string) {
-	services.ConnectToPostgres(contName, dbuser)
+func (a *App) RemoveBackupSchedule(container string) error {
+	return services.RemoveBackupSchedule(a.ctx, container)
 }
 
-func (a *App) OpenMongoTerminal(contName, dbuser string) {
-	services.ConnectToMongo(contName, dbuser)
+// OpenDatabaseTerminal opens the client of a database container in a
+// terminal.
+func (a *App) OpenDatabaseTerminal(contName string) error {
+	return services.OpenDatabaseTerminal(contName)
+}
+
+// mergePorts adds extra ports to a comma separated port list, skipping
+// the ones already listed.
+func mergePorts(ports string, extra []string) string {
+	list := strings.Split(ports, ",")
+	seen := make(map[string]bool)
+	var merged []string
+	for _, p := range append(list, extra...) {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		merged = append(merged, p)
+	}
+	return strings.Join(merged, ",")
 }
 
 func truncateString(s string, maxLength int) string {
@@ -6,6 +6,7 @@ import { FiPlus } from "react-icons/fi";
 import { IoSunny, IoMoon } from "react-icons/io5";
 import { ListAllContainersJSON, ListImages } from "../wailsjs/go/main/App";
 import { main } from "../wailsjs/go/models";
+import { EventsOn } from "../wailsjs/runtime/runtime";
 import ContainerDetails from "./components/ContainerDetails";
 import ImageDetails from "./components/ImageDetails";
 import CreateForm from "./components/CreateForm";
@@ -60,8 +61,18 @@ const App: React.FC = () => {
 
   useEffect(() => {
     fetchData();
-    const interval = setInterval(fetchData, 5000);
-    return () => clearInterval(interval);
+    const events = [
+      "container:created",
+      "container:started",
+      "container:died",
+      "container:destroyed",
+      "container:health",
+      "image:built",
+      "image:removed",
+      "ports:changed",
+    ];
+    const unsubscribers = events.map((event) => EventsOn(event, fetchData));
+    return () => unsubscribers.forEach((unsubscribe) => unsubscribe());
   }, [fetchData]);
 
   useEffect(() => {
@@ -21,8 +21,7 @@ import {
   GetMemoryStats,
   RemoveContainer,
   GetContainerMetrics,
-  OpenPostgresTerminal,
-  OpenMongoTerminal,
+  OpenDatabaseTerminal,
 } from "../../wailsjs/go/main/App";
 import {
   TooltipContent,
@@ -52,6 +51,7 @@ import {
   AlertDialogTitle,
 } from "./ui/alert-dialog";
 import { RadarChart } from "./ui/charts/RadarChart";
+import DatabaseBackups from "./DatabaseBackups";
 
 const MAX_DATA_POINTS = 15;
 
@@ -106,12 +106,12 @@ const ContainerDetails: React.FC<ContainerDetailsProps> = ({ container }) => {
     if (container.status.slice(0, 6) !== "Exited") {
       setIsRemoveDialogOpen(true);
     } else {
-      await RemoveContainer(id, false);
+      await RemoveContainer(id, false, !container.isdatabase);
     }
   };
 
   const handleForceRemove = async () => {
-    await RemoveContainer(container.id, true);
+    await RemoveContainer(container.id, true, !container.isdatabase);
     setIsRemoveDialogOpen(false);
   };
 
@@ -142,20 +142,13 @@ const ContainerDetails: React.FC<ContainerDetailsProps> = ({ container }) => {
 
   const getConnectionString = useCallback(
     (showPass: boolean = false) => {
-      const port =
-        container.public_ports && container.public_ports.length > 0
-          ? container.public_ports[0]
-          : "";
-      if (container.db.match("postgres")) {
-        return `postgresql://${container.dbuser}:${
-          showPass ? container.dbpass : "********"
-        }@localhost:${port}/${container.db}`;
-      } else if (container.db.match("mongo")) {
-        return `mongodb://${container.dbuser}:${
-          showPass ? container.dbpass : "********"
-        }@localhost:${port}/${container.db}`;
+      if (!container.connection || showPass) {
+        return container.connection;
       }
-      return "";
+      return container.connection.replace(
+        /^([^:]+:\/\/[^:@/]*:)[^@]*@/,
+        "$1********@"
+      );
     },
     [container]
   );
@@ -233,21 +226,14 @@ const ContainerDetails: React.FC<ContainerDetailsProps> = ({ container }) => {
                         variant="outline"
                         size="icon"
                         onClick={async () => {
-                          if (container.db.match("postgres")) {
-                            await OpenPostgresTerminal(
-                              container.name,
-                              container.dbuser
-                            );
-                          }
-                          if (container.db.match("mongo")) {
-                            await OpenMongoTerminal(
-                              container.name,
-                              container.dbuser
-                            );
+                          try {
+                            await OpenDatabaseTerminal(container.name);
+                          } catch (error) {
+                            console.error(error);
                           }
                         }}
                         className={`mr-2 ${
-                          container.dbuser &&
+                          container.db &&
                           container.status.slice(0, 6) !== "Exited"
                             ? ""
                             : "disabled:opacity-50 disabled:pointer-events-none"
@@ -415,6 +401,13 @@ const ContainerDetails: React.FC<ContainerDetailsProps> = ({ container }) => {
         </CardContent>
       </Card>
 
+      {container.isdatabase && (
+        <DatabaseBackups
+          container={container.id}
+          running={container.status.slice(0, 6) !== "Exited"}
+        />
+      )}
+
       {container.status.slice(0, 6) !== "Exited" ? (
         <div className="grid grid-cols-1 md:grid-cols-2 gap-1">
           <div className="space-y-4">
@@ -1,4 +1,4 @@
-import React, { useState } from "react";
+import React, { useEffect, useState } from "react";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import {
@@ -13,8 +13,14 @@ import {
 import {
   CreateCodeInstance,
   CreateDB,
+  DetectDevcontainer,
+  ListDatabaseEngines,
+  ListTechnologies,
+  ListTemplates,
   SelectFolder,
 } from "../../wailsjs/go/main/App";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
+import { services } from "../../wailsjs/go/models";
 import { Tabs, TabsList, TabsTrigger, TabsContent } from "./ui/tabs";
 import { IoFolderOpenOutline } from "react-icons/io5";
 import {
@@ -26,6 +32,14 @@ import {
 } from "@/components/ui/dialog";
 import { AiFillEye, AiFillEyeInvisible } from "react-icons/ai";
 
+// Platforms a workspace can be built for, auto uses the one of the daemon.
+const platforms = [
+  { value: "auto", label: "Auto (Docker host)" },
+  { value: "linux/amd64", label: "linux/amd64" },
+  { value: "linux/arm64", label: "linux/arm64" },
+  { value: "linux/arm/v7", label: "linux/arm/v7" },
+];
+
 interface CreateFormProps {
   open: boolean;
   onClose: () => void;
@@ -42,42 +56,115 @@ const CreateForm: React.FC<CreateFormProps> = ({
   const [activeTab, setActiveTab] = useState("package");
   const [containerName, setContainerName] = useState("");
   const [technology, setTechnology] = useState("");
+  const [version, setVersion] = useState("");
   const [template, setTemplate] = useState("");
   const [database, setDatabase] = useState("");
   const [folder, setFolder] = useState("");
+  const [devcontainer, setDevcontainer] =
+    useState<services.DevcontainerInfo | null>(null);
   const [port, setPort] = useState("");
+  const [platform, setPlatform] = useState("auto");
   const [dbname, setDbName] = useState("");
   const [dbpass, setDbPass] = useState("");
   const [dbuser, setDbUser] = useState("");
   const [showPassword, setShowPassword] = useState<boolean>(false);
+  const [templates, setTemplates] = useState<services.Template[]>([]);
+  const [runtimes, setRuntimes] = useState<services.Runtime[]>([]);
+  const [engines, setEngines] = useState<services.DatabaseEngineInfo[]>([]);
+
+  useEffect(() => {
+    ListDatabaseEngines()
+      .then((list) => setEngines(list || []))
+      .catch((error) => console.error("Error loading databases:", error));
+    ListTechnologies()
+      .then((list) => setRuntimes(list || []))
+      .catch((error) => console.error("Error loading technologies:", error));
+    ListTemplates()
+      .then((list) => setTemplates(list || []))
+      .catch((error) => console.error("Error loading templates:", error));
+  }, []);
+
+  const templateGroups = templates.reduce<Record<string, services.Template[]>>(
+    (groups, t) => {
+      (groups[t.group] = groups[t.group] || []).push(t);
+      return groups;
+    },
+    {}
+  );
+
+  const engineGroups = engines.reduce<
+    Record<string, services.DatabaseEngineInfo[]>
+  >((groups, e) => {
+    (groups[e.group] = groups[e.group] || []).push(e);
+    return groups;
+  }, {});
+  const selectedEngine = engines.find((e) => e.id === database);
 
   const togglePasswordVisibility = () => {
     setShowPassword(!showPassword);
   };
-  const choices = [
-    "NodeLTS",
-    "Node18",
-    "Node20",
-    "Python",
-    "Rust",
-    "Go",
-    "Java8",
-    "Java11",
-    "Java17",
-    "Java20",
-    "Java21",
-  ];
+  const selectedRuntime = runtimes.find((r) => r.id === technology);
+
+  const handleTechnologyChange = (id: string) => {
+    setTechnology(id);
+    setVersion(runtimes.find((r) => r.id === id)?.default || "");
+  };
+
+  const platformSelect = (
+    <Select value={platform} onValueChange={setPlatform}>
+      <SelectTrigger>
+        <SelectValue placeholder="Select Platform" />
+      </SelectTrigger>
+      <SelectContent>
+        {platforms.map((p) => (
+          <SelectItem value={p.value} key={p.value}>
+            {p.label}
+          </SelectItem>
+        ))}
+      </SelectContent>
+    </Select>
+  );
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     onClose();
     setIsCreating(true);
-    if (activeTab === "package") {
-      await CreateCodeInstance(containerName, technology, folder, port, "");
-    } else if (activeTab === "template") {
-      await CreateCodeInstance(containerName, "none", folder, port, template);
-    } else {
-      // dbtype, username, password, dbname, contname
+    if (activeTab === "package" || activeTab === "template") {
+      const targetPlatform = platform === "auto" ? "" : platform;
+      const jobId =
+        activeTab === "package"
+          ? await CreateCodeInstance(
+              containerName,
+              technology,
+              version,
+              folder,
+              port,
+              "",
+              targetPlatform
+            )
+          : await CreateCodeInstance(
+              containerName,
+              "none",
+              "",
+              folder,
+              port,
+              template,
+              targetPlatform
+            );
+      const finished = ["done", "failed", "cancelled"];
+      const unsubscribe = EventsOn("job:update", (job: services.Job) => {
+        if (job.id === jobId && finished.includes(job.phase)) {
+          unsubscribe();
+          if (job.error) {
+            console.error(job.error);
+          }
+          setIsCreating(false);
+        }
+      });
+      return;
+    }
+    // dbtype, username, password, dbname, contname
+    try {
       const id = await CreateDB(
         database,
         dbuser,
@@ -86,6 +173,8 @@ const CreateForm: React.FC<CreateFormProps> = ({
         containerName
       );
       console.log(id);
+    } catch (error) {
+      console.error(error);
     }
     setIsCreating(false);
   };
@@ -95,6 +184,7 @@ const CreateForm: React.FC<CreateFormProps> = ({
     try {
       const selectedFolderPath = await SelectFolder();
       setFolder(selectedFolderPath);
+      setDevcontainer(await DetectDevcontainer(selectedFolderPath));
     } catch (error) {
       console.error("Error selecting folder:", error);
     }
@@ -119,18 +209,32 @@ const CreateForm: React.FC<CreateFormProps> = ({
                 value={containerName}
                 onChange={(e) => setContainerName(e.target.value)}
               />
-              <Select value={technology} onValueChange={setTechnology}>
+              <Select value={technology} onValueChange={handleTechnologyChange}>
                 <SelectTrigger>
                   <SelectValue placeholder="Select Technology" />
                 </SelectTrigger>
                 <SelectContent>
-                  {choices.map((choice, index) => (
-                    <SelectItem value={choice.toLowerCase()} key={index}>
-                      {choice}
+                  {runtimes.map((r) => (
+                    <SelectItem value={r.id} key={r.id}>
+                      {r.name}
                     </SelectItem>
                   ))}
                 </SelectContent>
               </Select>
+              {selectedRuntime && (
+                <Select value={version} onValueChange={setVersion}>
+                  <SelectTrigger>
+                    <SelectValue placeholder="Select Version" />
+                  </SelectTrigger>
+                  <SelectContent>
+                    {selectedRuntime.versions.map((v) => (
+                      <SelectItem value={v.version} key={v.version}>
+                        {v.label}
+                      </SelectItem>
+                    ))}
+                  </SelectContent>
+                </Select>
+              )}
               <div className="relative">
                 <Input placeholder="Folder Path" value={folder} readOnly />
                 <Button
@@ -140,11 +244,18 @@ const CreateForm: React.FC<CreateFormProps> = ({
                   <IoFolderOpenOutline className="h-5 w-5" />
                 </Button>
               </div>
+              {devcontainer && (
+                <p className="text-sm text-muted-foreground">
+                  Using devcontainer.json
+                  {devcontainer.name ? ` (${devcontainer.name})` : ""}
+                </p>
+              )}
               <Input
                 placeholder="Port"
                 value={port}
                 onChange={(e) => setPort(e.target.value)}
               />
+              {platformSelect}
             </form>
           </TabsContent>
           <TabsContent value="template">
@@ -159,18 +270,16 @@ const CreateForm: React.FC<CreateFormProps> = ({
                   <SelectValue placeholder="Select Template" />
                 </SelectTrigger>
                 <SelectContent>
-                  <SelectGroup>
-                    <SelectLabel>Node</SelectLabel>
-                    <SelectItem value="next-js">Next-js</SelectItem>
-                    <SelectItem value="next-ts">Next-ts</SelectItem>
-                    <SelectItem value="nest">Nest</SelectItem>
-                  </SelectGroup>
-                  <SelectGroup>
-                    <SelectLabel>Go</SelectLabel>
-                    <SelectItem value="goftt">
-                      Go + Fiber + Templ + Tailwind
-                    </SelectItem>
-                  </SelectGroup>
+                  {Object.entries(templateGroups).map(([group, items]) => (
+                    <SelectGroup key={group}>
+                      <SelectLabel>{group}</SelectLabel>
+                      {items.map((t) => (
+                        <SelectItem value={t.name} key={t.name}>
+                          {t.title}
+                        </SelectItem>
+                      ))}
+                    </SelectGroup>
+                  ))}
                 </SelectContent>
               </Select>
               <div className="relative">
@@ -182,11 +291,18 @@ const CreateForm: React.FC<CreateFormProps> = ({
                   <IoFolderOpenOutline className="h-5 w-5" />
                 </Button>
               </div>
+              {devcontainer && (
+                <p className="text-sm text-muted-foreground">
+                  Using devcontainer.json
+                  {devcontainer.name ? ` (${devcontainer.name})` : ""}
+                </p>
+              )}
               <Input
                 placeholder="Port"
                 value={port}
                 onChange={(e) => setPort(e.target.value)}
               />
+              {platformSelect}
             </form>
           </TabsContent>
           <TabsContent value="database">
@@ -196,14 +312,16 @@ const CreateForm: React.FC<CreateFormProps> = ({
                   <SelectValue placeholder="Select Database" />
                 </SelectTrigger>
                 <SelectContent>
-                  <SelectGroup>
-                    <SelectLabel>SQL</SelectLabel>
-                    <SelectItem value="postgres">PostgreSQL</SelectItem>
-                  </SelectGroup>
-                  <SelectGroup>
-                    <SelectLabel>NoSQL</SelectLabel>
-                    <SelectItem value="mongo">MongoDB</SelectItem>
-                  </SelectGroup>
+                  {Object.entries(engineGroups).map(([group, list]) => (
+                    <SelectGroup key={group}>
+                      <SelectLabel>{group}</SelectLabel>
+                      {list.map((e) => (
+                        <SelectItem key={e.id} value={e.id}>
+                          {e.name}
+                        </SelectItem>
+                      ))}
+                    </SelectGroup>
+                  ))}
                 </SelectContent>
               </Select>
               <Input
@@ -211,16 +329,20 @@ const CreateForm: React.FC<CreateFormProps> = ({
                 value={containerName}
                 onChange={(e) => setContainerName(e.target.value)}
               />
-              <Input
-                placeholder="Database Name"
-                value={dbname}
-                onChange={(e) => setDbName(e.target.value)}
-              />
-              <Input
-                placeholder="Database Username"
-                value={dbuser}
-                onChange={(e) => setDbUser(e.target.value)}
-              />
+              {selectedEngine?.databases !== false && (
+                <Input
+                  placeholder="Database Name"
+                  value={dbname}
+                  onChange={(e) => setDbName(e.target.value)}
+                />
+              )}
+              {selectedEngine?.users !== false && (
+                <Input
+                  placeholder="Database Username"
+                  value={dbuser}
+                  onChange={(e) => setDbUser(e.target.value)}
+                />
+              )}
               <div className="relative">
                 <Input
                   placeholder="Database Password"
@@ -0,0 +1,221 @@
+import React, { useCallback, useEffect, useState } from "react";
+import { Card, CardContent, CardHeader, CardTitle } from "./ui/card";
+import { Button } from "./ui/button";
+import { Input } from "./ui/input";
+import { Label } from "./ui/label";
+import { FiRotateCcw } from "react-icons/fi";
+import { services } from "../../wailsjs/go/models";
+import {
+  BackupDatabase,
+  GetBackupSchedule,
+  ListBackups,
+  RemoveBackupSchedule,
+  RestoreBackup,
+  SetBackupSchedule,
+} from "../../wailsjs/go/main/App";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
+import {
+  TooltipContent,
+  TooltipProvider,
+  TooltipTrigger,
+  Tooltip as ShadTooltip,
+} from "./ui/tooltip";
+
+interface DatabaseBackupsProps {
+  // container is the ID of the database, its name may be truncated
+  container: string;
+  running: boolean;
+}
+
+const DEFAULT_RETENTION = "7";
+
+const formatSize = (bytes: number) => {
+  const units = ["B", "KiB", "MiB", "GiB"];
+  let size = bytes;
+  let unit = 0;
+  while (size >= 1024 && unit < units.length - 1) {
+    size /= 1024;
+    unit++;
+  }
+  return `${size.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
+};
+
+const DatabaseBackups: React.FC<DatabaseBackupsProps> = ({
+  container,
+  running,
+}) => {
+  const [backups, setBackups] = useState<services.Backup[]>([]);
+  const [schedule, setSchedule] = useState<services.BackupSchedule | null>(
+    null
+  );
+  const [scheduleInput, setScheduleInput] = useState("");
+  const [retentionInput, setRetentionInput] = useState(DEFAULT_RETENTION);
+  const [busy, setBusy] = useState("");
+  const [error, setError] = useState("");
+
+  const fetchBackups = useCallback(async () => {
+    try {
+      const [list, current] = await Promise.all([
+        ListBackups(container),
+        GetBackupSchedule(container),
+      ]);
+      setBackups(list || []);
+      setSchedule(current);
+      setScheduleInput(current?.schedule || "");
+      setRetentionInput(
+        current ? String(current.retention) : DEFAULT_RETENTION
+      );
+    } catch (error) {
+      console.error("Error loading backups:", error);
+    }
+  }, [container]);
+
+  useEffect(() => {
+    fetchBackups();
+    // The event names the container while this view only knows its ID,
+    // so any finished backup refreshes the list
+    const unsubscribe = EventsOn("backup:finished", fetchBackups);
+    return () => unsubscribe();
+  }, [container, fetchBackups]);
+
+  // run marks the action as busy while it runs and shows its error, if any
+  const run = async (action: string, fn: () => Promise<unknown>) => {
+    setBusy(action);
+    setError("");
+    try {
+      await fn();
+    } catch (error) {
+      setError(String(error));
+    } finally {
+      setBusy("");
+      fetchBackups();
+    }
+  };
+
+  const handleRetentionChange = (e: React.ChangeEvent<HTMLInputElement>) => {
+    const value = e.target.value;
+    if (value === "" || /^\d+$/.test(value)) {
+      setRetentionInput(value);
+    }
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle className="flex justify-between items-center">
+          <span>Backups</span>
+          <Button
+            variant="outline"
+            disabled={!running || busy !== ""}
+            onClick={() => run("backup", () => BackupDatabase(container))}
+          >
+            {busy === "backup" ? "Backing up..." : "Back up now"}
+          </Button>
+        </CardTitle>
+      </CardHeader>
+      <CardContent className="space-y-4">
+        <form
+          className="flex items-end space-x-2"
+          onSubmit={(e) => {
+            e.preventDefault();
+            run("schedule", () =>
+              SetBackupSchedule(
+                container,
+                scheduleInput,
+                parseInt(retentionInput, 10) || 0
+              )
+            );
+          }}
+        >
+          <div className="flex-grow space-y-1">
+            <Label htmlFor="backup-schedule">Schedule</Label>
+            <Input
+              id="backup-schedule"
+              value={scheduleInput}
+              onChange={(e) => setScheduleInput(e.target.value)}
+              placeholder="hourly, daily or a cron expression"
+            />
+          </div>
+          <div className="w-24 space-y-1">
+            <Label htmlFor="backup-retention">Keep</Label>
+            <Input
+              id="backup-retention"
+              type="text"
+              inputMode="numeric"
+              value={retentionInput}
+              onChange={handleRetentionChange}
+            />
+          </div>
+          <Button type="submit" disabled={!scheduleInput || busy !== ""}>
+            Save
+          </Button>
+          {schedule && (
+            <Button
+              type="button"
+              variant="outline"
+              disabled={busy !== ""}
+              onClick={() =>
+                run("schedule", () => RemoveBackupSchedule(container))
+              }
+            >
+              Remove
+            </Button>
+          )}
+        </form>
+        {schedule?.last_run && (
+          <p className="text-sm text-muted-foreground">
+            Last run: {new Date(schedule.last_run).toLocaleString()}
+            {schedule.last_error ? ` (failed: ${schedule.last_error})` : ""}
+          </p>
+        )}
+        {error && <p className="text-sm text-red-500">{error}</p>}
+        {backups.length === 0 ? (
+          <p className="text-sm text-gray-500">No backups yet</p>
+        ) : (
+          <ul className="divide-y">
+            {backups.map((backup) => (
+              <li
+                key={backup.name}
+                className="flex justify-between items-center py-2"
+              >
+                <div>
+                  <p>{new Date(backup.created).toLocaleString()}</p>
+                  <p className="text-sm text-muted-foreground">
+                    {backup.name} · {formatSize(backup.size)}
+                  </p>
+                </div>
+                <TooltipProvider>
+                  <ShadTooltip>
+                    <TooltipTrigger asChild>
+                      <Button
+                        variant="outline"
+                        size="icon"
+                        disabled={!running || busy !== ""}
+                        onClick={() =>
+                          run(backup.name, () =>
+                            RestoreBackup(container, backup.name)
+                          )
+                        }
+                      >
+                        <FiRotateCcw
+                          className={
+                            busy === backup.name ? "animate-spin" : ""
+                          }
+                        />
+                      </Button>
+                    </TooltipTrigger>
+                    <TooltipContent>
+                      <p>Restore</p>
+                    </TooltipContent>
+                  </ShadTooltip>
+                </TooltipProvider>
+              </li>
+            ))}
+          </ul>
+        )}
+      </CardContent>
+    </Card>
+  );
+};
+
+export default DatabaseBackups;
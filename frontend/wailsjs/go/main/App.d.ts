@@ -1,39 +1,100 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {services} from '../models';
 import {main} from '../models';
 
-export function CreateCodeInstance(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<string>;
+export function BackupDatabase(arg1:string):Promise<services.Backup>;
+
+export function CancelJob(arg1:string):Promise<void>;
+
+export function ClearBaseImageCache():Promise<Array<string>>;
+
+export function CloneWorkspace(arg1:string,arg2:string,arg3:string,arg4:boolean):Promise<string>;
+
+export function CollectImages(arg1:boolean):Promise<services.ImageGCReport>;
+
+export function CreateCodeInstance(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string,arg7:string):Promise<string>;
 
 export function CreateDB(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<string>;
 
+export function CreateSnapshot(arg1:string,arg2:string):Promise<services.Snapshot>;
+
+export function DetectDevcontainer(arg1:string):Promise<services.DevcontainerInfo>;
+
+export function DumpDatabase(arg1:string,arg2:string):Promise<void>;
+
+export function ExportLogs(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function ExportWorkspace(arg1:string,arg2:boolean):Promise<string>;
+
 export function ForceRemoveContainer(arg1:string):Promise<void>;
 
+export function GetBackupSchedule(arg1:string):Promise<services.BackupSchedule>;
+
 export function GetCPUStats(arg1:string):Promise<Array<main.CPUStats>>;
 
 export function GetContainerMetrics(arg1:string):Promise<main.ContainerMetrics>;
 
 export function GetImageLayerSize(arg1:string):Promise<Array<main.LayerInfo>>;
 
+export function GetJob(arg1:string):Promise<services.Job>;
+
 export function GetMemoryStats(arg1:string):Promise<Array<main.MemoryStats>>;
 
 export function Greet(arg1:string):Promise<string>;
 
+export function ImportWorkspace(arg1:string):Promise<string>;
+
 export function ListAllContainersJSON():Promise<Array<main.containerDetail>>;
 
+export function ListBackups(arg1:string):Promise<Array<services.Backup>>;
+
+export function ListBaseImages():Promise<Array<services.BaseImage>>;
+
+export function ListDatabaseEngines():Promise<Array<services.DatabaseEngineInfo>>;
+
 export function ListImages():Promise<Array<main.imageDetail>>;
 
-export function OpenMongoTerminal(arg1:string,arg2:string):Promise<void>;
+export function ListJobs():Promise<Array<services.Job>>;
+
+export function ListSnapshots(arg1:string):Promise<Array<services.Snapshot>>;
+
+export function ListTechnologies():Promise<Array<services.Runtime>>;
+
+export function ListTemplates():Promise<Array<services.Template>>;
+
+export function ListVolumes():Promise<Array<services.Volume>>;
+
+export function OpenDatabaseTerminal(arg1:string):Promise<void>;
+
+export function RebuildWorkspace(arg1:string,arg2:services.RebuildOptions):Promise<string>;
+
+export function ReconcileStore():Promise<services.ReconcileReport>;
 
-export function OpenPostgresTerminal(arg1:string,arg2:string):Promise<void>;
+export function RemoveBackupSchedule(arg1:string):Promise<void>;
 
-export function RemoveContainer(arg1:string,arg2:boolean):Promise<void>;
+export function RemoveContainer(arg1:string,arg2:boolean,arg3:boolean):Promise<void>;
 
 export function RemoveImages(arg1:string,arg2:boolean,arg3:boolean):Promise<void>;
 
+export function RemoveVolume(arg1:string):Promise<void>;
+
+export function RestoreBackup(arg1:string,arg2:string):Promise<void>;
+
+export function RestoreDatabase(arg1:string,arg2:string):Promise<void>;
+
+export function RestoreSnapshot(arg1:string,arg2:string):Promise<services.Snapshot>;
+
 export function SelectFolder():Promise<string>;
 
+export function SetBackupSchedule(arg1:string,arg2:string,arg3:number):Promise<void>;
+
 export function StartContainer(arg1:string,arg2:string):Promise<string>;
 
 export function StopContainer(arg1:string):Promise<string>;
 
+export function StopLogStream(arg1:string):Promise<void>;
+
+export function StreamLogs(arg1:string,arg2:boolean,arg3:string,arg4:string):Promise<void>;
+
 export function URL(arg1:string):Promise<void>;
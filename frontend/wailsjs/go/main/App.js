@@ -2,18 +2,62 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function CreateCodeInstance(arg1, arg2, arg3, arg4, arg5) {
-  return window['go']['main']['App']['CreateCodeInstance'](arg1, arg2, arg3, arg4, arg5);
+export function BackupDatabase(arg1) {
+  return window['go']['main']['App']['BackupDatabase'](arg1);
+}
+
+export function CancelJob(arg1) {
+  return window['go']['main']['App']['CancelJob'](arg1);
+}
+
+export function ClearBaseImageCache() {
+  return window['go']['main']['App']['ClearBaseImageCache']();
+}
+
+export function CloneWorkspace(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['CloneWorkspace'](arg1, arg2, arg3, arg4);
+}
+
+export function CollectImages(arg1) {
+  return window['go']['main']['App']['CollectImages'](arg1);
+}
+
+export function CreateCodeInstance(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
+  return window['go']['main']['App']['CreateCodeInstance'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
 }
 
 export function CreateDB(arg1, arg2, arg3, arg4, arg5) {
   return window['go']['main']['App']['CreateDB'](arg1, arg2, arg3, arg4, arg5);
 }
 
+export function CreateSnapshot(arg1, arg2) {
+  return window['go']['main']['App']['CreateSnapshot'](arg1, arg2);
+}
+
+export function DetectDevcontainer(arg1) {
+  return window['go']['main']['App']['DetectDevcontainer'](arg1);
+}
+
+export function DumpDatabase(arg1, arg2) {
+  return window['go']['main']['App']['DumpDatabase'](arg1, arg2);
+}
+
+export function ExportLogs(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportLogs'](arg1, arg2, arg3);
+}
+
+export function ExportWorkspace(arg1, arg2) {
+  return window['go']['main']['App']['ExportWorkspace'](arg1, arg2);
+}
+
 export function ForceRemoveContainer(arg1) {
   return window['go']['main']['App']['ForceRemoveContainer'](arg1);
 }
 
+export function GetBackupSchedule(arg1) {
+  return window['go']['main']['App']['GetBackupSchedule'](arg1);
+}
+
 export function GetCPUStats(arg1) {
   return window['go']['main']['App']['GetCPUStats'](arg1);
 }
@@ -26,6 +70,10 @@ export function GetImageLayerSize(arg1) {
   return window['go']['main']['App']['GetImageLayerSize'](arg1);
 }
 
+export function GetJob(arg1) {
+  return window['go']['main']['App']['GetJob'](arg1);
+}
+
 export function GetMemoryStats(arg1) {
   return window['go']['main']['App']['GetMemoryStats'](arg1);
 }
@@ -34,34 +82,98 @@ export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ImportWorkspace(arg1) {
+  return window['go']['main']['App']['ImportWorkspace'](arg1);
+}
+
 export function ListAllContainersJSON() {
   return window['go']['main']['App']['ListAllContainersJSON']();
 }
 
+export function ListBackups(arg1) {
+  return window['go']['main']['App']['ListBackups'](arg1);
+}
+
+export function ListBaseImages() {
+  return window['go']['main']['App']['ListBaseImages']();
+}
+
+export function ListDatabaseEngines() {
+  return window['go']['main']['App']['ListDatabaseEngines']();
+}
+
 export function ListImages() {
   return window['go']['main']['App']['ListImages']();
 }
 
-export function OpenMongoTerminal(arg1, arg2) {
-  return window['go']['main']['App']['OpenMongoTerminal'](arg1, arg2);
+export function ListJobs() {
+  return window['go']['main']['App']['ListJobs']();
+}
+
+export function ListSnapshots(arg1) {
+  return window['go']['main']['App']['ListSnapshots'](arg1);
+}
+
+export function ListTechnologies() {
+  return window['go']['main']['App']['ListTechnologies']();
+}
+
+export function ListTemplates() {
+  return window['go']['main']['App']['ListTemplates']();
+}
+
+export function ListVolumes() {
+  return window['go']['main']['App']['ListVolumes']();
+}
+
+export function OpenDatabaseTerminal(arg1) {
+  return window['go']['main']['App']['OpenDatabaseTerminal'](arg1);
+}
+
+export function RebuildWorkspace(arg1, arg2) {
+  return window['go']['main']['App']['RebuildWorkspace'](arg1, arg2);
+}
+
+export function ReconcileStore() {
+  return window['go']['main']['App']['ReconcileStore']();
 }
 
-export function OpenPostgresTerminal(arg1, arg2) {
-  return window['go']['main']['App']['OpenPostgresTerminal'](arg1, arg2);
+export function RemoveBackupSchedule(arg1) {
+  return window['go']['main']['App']['RemoveBackupSchedule'](arg1);
 }
 
-export function RemoveContainer(arg1, arg2) {
-  return window['go']['main']['App']['RemoveContainer'](arg1, arg2);
+export function RemoveContainer(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RemoveContainer'](arg1, arg2, arg3);
 }
 
 export function RemoveImages(arg1, arg2, arg3) {
   return window['go']['main']['App']['RemoveImages'](arg1, arg2, arg3);
 }
 
+export function RemoveVolume(arg1) {
+  return window['go']['main']['App']['RemoveVolume'](arg1);
+}
+
+export function RestoreBackup(arg1, arg2) {
+  return window['go']['main']['App']['RestoreBackup'](arg1, arg2);
+}
+
+export function RestoreDatabase(arg1, arg2) {
+  return window['go']['main']['App']['RestoreDatabase'](arg1, arg2);
+}
+
+export function RestoreSnapshot(arg1, arg2) {
+  return window['go']['main']['App']['RestoreSnapshot'](arg1, arg2);
+}
+
 export function SelectFolder() {
   return window['go']['main']['App']['SelectFolder']();
 }
 
+export function SetBackupSchedule(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SetBackupSchedule'](arg1, arg2, arg3);
+}
+
 export function StartContainer(arg1, arg2) {
   return window['go']['main']['App']['StartContainer'](arg1, arg2);
 }
@@ -70,6 +182,14 @@ export function StopContainer(arg1) {
   return window['go']['main']['App']['StopContainer'](arg1);
 }
 
+export function StopLogStream(arg1) {
+  return window['go']['main']['App']['StopLogStream'](arg1);
+}
+
+export function StreamLogs(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['StreamLogs'](arg1, arg2, arg3, arg4);
+}
+
 export function URL(arg1) {
   return window['go']['main']['App']['URL'](arg1);
 }
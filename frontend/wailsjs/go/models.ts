@@ -78,6 +78,8 @@ export namespace main {
 	    dbuser: string;
 	    db: string;
 	    dbpass: string;
+	    dbname: string;
+	    connection: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new containerDetail(source);
@@ -98,6 +100,8 @@ export namespace main {
 	        this.dbuser = source["dbuser"];
 	        this.db = source["db"];
 	        this.dbpass = source["dbpass"];
+	        this.dbname = source["dbname"];
+	        this.connection = source["connection"];
 	    }
 	}
 	export class imageDetail {
@@ -127,3 +131,384 @@ export namespace main {
 
 }
 
+export namespace services {
+	
+	export class Backup {
+	    name: string;
+	    path: string;
+	    size: number;
+	    created: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Backup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.path = source["path"];
+	        this.size = source["size"];
+	        this.created = source["created"];
+	    }
+	}
+	export class BackupSchedule {
+	    container: string;
+	    schedule: string;
+	    retention: number;
+	    last_run: string;
+	    last_error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BackupSchedule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.container = source["container"];
+	        this.schedule = source["schedule"];
+	        this.retention = source["retention"];
+	        this.last_run = source["last_run"];
+	        this.last_error = source["last_error"];
+	    }
+	}
+	export class BaseImage {
+	    tag: string;
+	    image_id: string;
+	    runtime: string;
+	    version: string;
+	    arch: string;
+	    hash: string;
+	    size: number;
+	    created: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BaseImage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tag = source["tag"];
+	        this.image_id = source["image_id"];
+	        this.runtime = source["runtime"];
+	        this.version = source["version"];
+	        this.arch = source["arch"];
+	        this.hash = source["hash"];
+	        this.size = source["size"];
+	        this.created = source["created"];
+	    }
+	}
+	export class DatabaseEngineInfo {
+	    id: string;
+	    name: string;
+	    group: string;
+	    port: number;
+	    users: boolean;
+	    databases: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DatabaseEngineInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.group = source["group"];
+	        this.port = source["port"];
+	        this.users = source["users"];
+	        this.databases = source["databases"];
+	    }
+	}
+	export class DevcontainerInfo {
+	    path: string;
+	    name: string;
+	    image: string;
+	    dockerfile: string;
+	    ports: string[];
+	    env: {[key: string]: string};
+	    postCreateCommand: string;
+	    extensions: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DevcontainerInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.name = source["name"];
+	        this.image = source["image"];
+	        this.dockerfile = source["dockerfile"];
+	        this.ports = source["ports"];
+	        this.env = source["env"];
+	        this.postCreateCommand = source["postCreateCommand"];
+	        this.extensions = source["extensions"];
+	    }
+	}
+	export class UnusedImage {
+	    image_id: string;
+	    tags: string[];
+	    size: number;
+	    reclaimable: number;
+	    created: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new UnusedImage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.image_id = source["image_id"];
+	        this.tags = source["tags"];
+	        this.size = source["size"];
+	        this.reclaimable = source["reclaimable"];
+	        this.created = source["created"];
+	    }
+	}
+	export class ImageGCReport {
+	    dry_run: boolean;
+	    images: UnusedImage[];
+	    reclaimable: number;
+	    removed: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ImageGCReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.dry_run = source["dry_run"];
+	        this.images = this.convertValues(source["images"], UnusedImage);
+	        this.reclaimable = source["reclaimable"];
+	        this.removed = source["removed"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Job {
+	    id: string;
+	    kind: string;
+	    name: string;
+	    phase: string;
+	    containerId: string;
+	    error: string;
+	    startedAt: string;
+	    finishedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Job(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.kind = source["kind"];
+	        this.name = source["name"];
+	        this.phase = source["phase"];
+	        this.containerId = source["containerId"];
+	        this.error = source["error"];
+	        this.startedAt = source["startedAt"];
+	        this.finishedAt = source["finishedAt"];
+	    }
+	}
+	export class RebuildOptions {
+	    version: string;
+	    platform: string;
+	    refresh: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RebuildOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.platform = source["platform"];
+	        this.refresh = source["refresh"];
+	    }
+	}
+	export class ReconcileChange {
+	    action: string;
+	    name: string;
+	    container_id: string;
+	    detail?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReconcileChange(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.action = source["action"];
+	        this.name = source["name"];
+	        this.container_id = source["container_id"];
+	        this.detail = source["detail"];
+	    }
+	}
+	export class ReconcileReport {
+	    changes: ReconcileChange[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ReconcileReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.changes = this.convertValues(source["changes"], ReconcileChange);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RuntimeVersion {
+	    version: string;
+	    label: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RuntimeVersion(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.label = source["label"];
+	    }
+	}
+	export class Runtime {
+	    id: string;
+	    name: string;
+	    default: string;
+	    versions: RuntimeVersion[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Runtime(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.default = source["default"];
+	        this.versions = this.convertValues(source["versions"], RuntimeVersion);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Snapshot {
+	    tag: string;
+	    image_id: string;
+	    note: string;
+	    created: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Snapshot(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tag = source["tag"];
+	        this.image_id = source["image_id"];
+	        this.note = source["note"];
+	        this.created = source["created"];
+	    }
+	}
+	export class Template {
+	    name: string;
+	    title: string;
+	    description: string;
+	    group: string;
+	    technology: string;
+	    version: string;
+	    ports: string[];
+	    scaffold: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Template(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.title = source["title"];
+	        this.description = source["description"];
+	        this.group = source["group"];
+	        this.technology = source["technology"];
+	        this.version = source["version"];
+	        this.ports = source["ports"];
+	        this.scaffold = source["scaffold"];
+	    }
+	}
+	
+	export class Volume {
+	    name: string;
+	    database: string;
+	    engine: string;
+	    created: string;
+	    size: number;
+	    in_use: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Volume(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.database = source["database"];
+	        this.engine = source["engine"];
+	        this.created = source["created"];
+	        this.size = source["size"];
+	        this.in_use = source["in_use"];
+	    }
+	}
+
+}
+
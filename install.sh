@@ -90,13 +90,6 @@ setup_contanize() {
         exit 1
     }
 
-    echo -e "\n${BLUE}Moving Essentials...${NC}"
-    sudo cp LinuxBuild/dockerfile LinuxBuild/settings.json LinuxBuild/setup.sh /usr/local/share/contanize/ || {
-        echo -e "${RED}Failed to copy essentials files.${NC}"
-        remove_repo
-        exit 1
-    }
-
     echo -e "\n${BLUE}Moving contanize.png...${NC}"
     sudo cp LinuxBuild/contanize.png /usr/share/pixmaps/ || {
         echo -e "${RED}Failed to move contanize.png.${NC}"
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"contanize/services"
 	"embed"
+	"io/fs"
 	"log"
 
 	"github.com/wailsapp/wails/v2"
@@ -19,12 +21,21 @@ var assets embed.FS
 //go:embed build/appicon.png
 var icon []byte
 
+//go:embed LinuxBuild/dockerfile LinuxBuild/dockerfile.workspace LinuxBuild/dockerfile.devcontainer LinuxBuild/setup.sh LinuxBuild/settings.json LinuxBuild/templates
+var buildContext embed.FS
+
 func main() {
+	linuxBuild, err := fs.Sub(buildContext, "LinuxBuild")
+	if err != nil {
+		log.Fatal(err)
+	}
+	services.SetBuildContext(linuxBuild)
+
 	// Create an instance of the app structure
 	app := NewApp()
 
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:             "Contanize",
 		Width:             1200,
 		Height:            900,
@@ -0,0 +1,566 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// EventBackupFinished is emitted when a scheduled backup has run.
+const EventBackupFinished = "backup:finished"
+
+// BackupSchedule is the backup plan of a database container, kept in the
+// store under the name of the container.
+type BackupSchedule struct {
+	Container string `yaml:"container" json:"container"`
+	// Schedule is hourly, daily or a five field cron expression such as
+	// "30 2 * * 1-5".
+	Schedule string `yaml:"schedule" json:"schedule"`
+	// Retention is the number of backups kept, older ones are deleted.
+	Retention int    `yaml:"retention" json:"retention"`
+	LastRun   string `yaml:"last_run,omitempty" json:"last_run"`
+	LastError string `yaml:"last_error,omitempty" json:"last_error"`
+}
+
+// Backup is a dump of a database kept in its backup directory.
+type Backup struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Created string `json:"created"`
+}
+
+type BackupRun struct {
+	Container string  `json:"container"`
+	Backup    *Backup `json:"backup"`
+	Error     string  `json:"error"`
+}
+
+func (t *Transaction) BackupSchedule(container string) (*BackupSchedule, bool) {
+	for _, schedule := range t.store.Backups {
+		if schedule.Container == container {
+			return &schedule, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Transaction) SetBackupSchedule(schedule BackupSchedule) {
+	for i := range t.store.Backups {
+		if t.store.Backups[i].Container == schedule.Container {
+			t.store.Backups[i] = schedule
+			return
+		}
+	}
+	t.store.Backups = append(t.store.Backups, schedule)
+}
+
+func (t *Transaction) DeleteBackupSchedule(container string) bool {
+	for i, schedule := range t.store.Backups {
+		if schedule.Container == container {
+			t.store.Backups = append(t.store.Backups[:i], t.store.Backups[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// backupKey returns the name the schedule and the backups of a database
+// container are kept under, given its ID or its name. A container that no
+// longer exists is taken to be called as given, so that the backups of a
+// removed database stay reachable.
+func backupKey(ctx context.Context, container string) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+	info, err := cli.ContainerInspect(ctx, container)
+	if client.IsErrNotFound(err) {
+		return container, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %v", err)
+	}
+	return strings.TrimPrefix(info.Name, "/"), nil
+}
+
+// GetBackupSchedule returns the backup schedule of a database container,
+// or nil if it has none.
+func GetBackupSchedule(ctx context.Context, container string) (*BackupSchedule, error) {
+	container, err := backupKey(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	transaction, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.rollback()
+	schedule, _ := transaction.BackupSchedule(container)
+	return schedule, nil
+}
+
+// SetBackupSchedule schedules backups of a database container, replacing
+// its previous schedule. The container must be a database whose engine
+// supports dumps.
+func SetBackupSchedule(ctx context.Context, container, schedule string, retention int) error {
+	if _, err := parseSchedule(schedule); err != nil {
+		return err
+	}
+	if retention < 1 {
+		return &InputError{Field: "retention", Reason: "keep at least one backup"}
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+	info, err := cli.ContainerInspect(ctx, container)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %v", err)
+	}
+	if info.Config.Labels["type"] != "Database" {
+		return fmt.Errorf("%s is not a database", container)
+	}
+	engine, err := FindDatabaseEngine(info.Config.Labels["db"])
+	if err != nil {
+		return err
+	}
+	if engine.DumpCmd(DatabaseCredentialsFromLabels(info.Config.Labels), dumpDir) == nil {
+		return fmt.Errorf("%s databases cannot be backed up", engine.Info().Name)
+	}
+	container = strings.TrimPrefix(info.Name, "/")
+
+	transaction, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer transaction.rollback()
+	updated := BackupSchedule{Container: container}
+	if previous, ok := transaction.BackupSchedule(container); ok {
+		updated = *previous
+	}
+	updated.Schedule = strings.TrimSpace(schedule)
+	updated.Retention = retention
+	transaction.SetBackupSchedule(updated)
+	if err := transaction.commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// RemoveBackupSchedule stops the scheduled backups of a database
+// container. Its existing backups are kept.
+func RemoveBackupSchedule(ctx context.Context, container string) error {
+	container, err := backupKey(ctx, container)
+	if err != nil {
+		return err
+	}
+	transaction, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer transaction.rollback()
+	if !transaction.DeleteBackupSchedule(container) {
+		return nil
+	}
+	if err := transaction.commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// BackupDir returns the directory holding the backups of a database
+// container.
+func BackupDir(container string) (string, error) {
+	if !containerNamePattern.MatchString(container) {
+		return "", &InputError{Field: "container name", Reason: "use letters, digits, '_', '.' or '-', starting with a letter or digit"}
+	}
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups", container), nil
+}
+
+// BackupDatabase dumps a running database container into its backup
+// directory. With retention above zero, only that many of the newest
+// backups are kept.
+func BackupDatabase(ctx context.Context, container string, retention int, emit EventFunc) (*Backup, error) {
+	container, err := backupKey(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := BackupDir(container)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	filename, tempFile, err := createBackupFile(dir, container)
+	if err != nil {
+		return nil, err
+	}
+	if err := DumpDatabase(ctx, container, tempFile, emit); err != nil {
+		os.Remove(tempFile)
+		return nil, err
+	}
+	if err := os.Rename(tempFile, filename); err != nil {
+		os.Remove(tempFile)
+		return nil, fmt.Errorf("failed to save backup: %v", err)
+	}
+
+	backups, err := readBackups(container)
+	if err != nil {
+		return nil, err
+	}
+	if retention > 0 && len(backups) > retention {
+		for _, backup := range backups[retention:] {
+			if err := os.Remove(backup.Path); err != nil {
+				log.Printf("Failed to remove backup %s: %v", backup.Path, err)
+			}
+		}
+	}
+	for _, backup := range backups {
+		if backup.Path == filename {
+			return &backup, nil
+		}
+	}
+	return nil, fmt.Errorf("backup %s was not written", filename)
+}
+
+// createBackupFile reserves the name of a new backup, named after the
+// container and the current time, and returns it together with the
+// temporary file the dump is written to. The temporary file is created
+// exclusively, so backups started at the same moment do not overwrite each
+// other, and it is only listed once renamed to the backup name.
+func createBackupFile(dir, container string) (filename, tempFile string, err error) {
+	for {
+		filename = filepath.Join(dir, fmt.Sprintf("%s-%s.dump", container, time.Now().Format("20060102-150405.000")))
+		tempFile = filename + ".tmp"
+		f, err := os.OpenFile(tempFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create backup: %v", err)
+		}
+		if _, err := os.Stat(filename); err == nil {
+			f.Close()
+			os.Remove(tempFile)
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		return filename, tempFile, f.Close()
+	}
+}
+
+// ListBackups returns the backups of a database container, newest first.
+func ListBackups(ctx context.Context, container string) ([]Backup, error) {
+	container, err := backupKey(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+	return readBackups(container)
+}
+
+// readBackups lists the backup directory of the container called name.
+func readBackups(container string) ([]Backup, error) {
+	dir, err := BackupDir(container)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %v", err)
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".dump") {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Name:    entry.Name(),
+			Path:    filepath.Join(dir, entry.Name()),
+			Size:    fi.Size(),
+			Created: fi.ModTime().Format(time.RFC3339),
+		})
+	}
+	// The timestamp in the name sorts in creation order
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// RestoreBackup loads the backup called name into a running database
+// container.
+func RestoreBackup(ctx context.Context, container, name string, emit EventFunc) error {
+	container, err := backupKey(ctx, container)
+	if err != nil {
+		return err
+	}
+	dir, err := BackupDir(container)
+	if err != nil {
+		return err
+	}
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".dump") {
+		return fmt.Errorf("%s is not a backup of %s", name, container)
+	}
+	filename := filepath.Join(dir, name)
+	if _, err := os.Stat(filename); err != nil {
+		return fmt.Errorf("%s is not a backup of %s", name, container)
+	}
+	return RestoreDatabase(ctx, container, filename, emit)
+}
+
+// BackupScheduler runs the scheduled backups while the app is open.
+// Backups missed while it was closed are caught up once at startup.
+type BackupScheduler struct {
+	emit EventFunc
+	mu   sync.Mutex
+}
+
+func NewBackupScheduler(emit EventFunc) *BackupScheduler {
+	return &BackupScheduler{emit: emit}
+}
+
+// Run checks the schedules every minute until ctx is cancelled.
+func (s *BackupScheduler) Run(ctx context.Context) {
+	for {
+		s.runDue(ctx)
+		now := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+	}
+}
+
+func (s *BackupScheduler) runDue(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The store must not stay locked while the dumps run
+	transaction, err := openStore()
+	if err != nil {
+		log.Printf("Failed to read backup schedules: %v", err)
+		return
+	}
+	schedules := append([]BackupSchedule{}, transaction.store.Backups...)
+	transaction.rollback()
+
+	now := time.Now()
+	for _, schedule := range schedules {
+		spec, err := parseSchedule(schedule.Schedule)
+		if err != nil {
+			log.Printf("Invalid backup schedule of %s: %v", schedule.Container, err)
+			continue
+		}
+		last, _ := time.Parse(time.RFC3339, schedule.LastRun)
+		if !spec.due(last, now) {
+			continue
+		}
+
+		run := BackupRun{Container: schedule.Container}
+		run.Backup, err = BackupDatabase(ctx, schedule.Container, schedule.Retention, s.emit)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Scheduled backup of %s failed: %v", schedule.Container, err)
+			run.Error = err.Error()
+		}
+
+		if err := s.recordRun(schedule.Container, now, run.Error); err != nil {
+			log.Printf("Failed to record backup of %s: %v", schedule.Container, err)
+		}
+		if s.emit != nil {
+			s.emit(EventBackupFinished, run)
+		}
+	}
+}
+
+func (s *BackupScheduler) recordRun(container string, at time.Time, runError string) error {
+	transaction, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer transaction.rollback()
+	schedule, ok := transaction.BackupSchedule(container)
+	if !ok {
+		return nil
+	}
+	schedule.LastRun = at.Format(time.RFC3339)
+	schedule.LastError = runError
+	transaction.SetBackupSchedule(*schedule)
+	if err := transaction.commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// backupSpec decides when a backup is due given the time of the last one,
+// which is zero if there was none.
+type backupSpec interface {
+	due(last, now time.Time) bool
+}
+
+// intervalSpec runs a backup once the interval has passed since the last
+// one, and right away if there was none.
+type intervalSpec time.Duration
+
+func (i intervalSpec) due(last, now time.Time) bool {
+	return last.IsZero() || now.Sub(last) >= time.Duration(i)
+}
+
+// cronSpec is a five field cron expression. Each field is a bit set of the
+// values it matches.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// As in cron, a day matches either day field when both are restricted
+	domAny, dowAny bool
+}
+
+func parseSchedule(spec string) (backupSpec, error) {
+	switch strings.ToLower(strings.TrimSpace(spec)) {
+	case "hourly", "@hourly":
+		return intervalSpec(time.Hour), nil
+	case "daily", "@daily":
+		return intervalSpec(24 * time.Hour), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, &InputError{Field: "schedule", Reason: "use hourly, daily or a cron expression with five fields"}
+	}
+	var c cronSpec
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and *,
+// each optionally followed by a /step.
+func parseCronField(field string, min, max int) (uint64, error) {
+	invalid := &InputError{Field: "schedule", Reason: fmt.Sprintf("%q is not a valid cron field", field)}
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, invalid
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, invalid
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, invalid
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, invalid
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c cronSpec) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// due reports whether a matching minute passed after the last backup. The
+// first backup runs at the first matching minute.
+func (c cronSpec) due(last, now time.Time) bool {
+	now = now.Truncate(time.Minute)
+	t := now
+	if !last.IsZero() {
+		t = last.Truncate(time.Minute).Add(time.Minute)
+		if limit := now.AddDate(-1, 0, 0); t.Before(limit) {
+			t = limit
+		}
+	}
+
+	for !t.After(now) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return true
+		}
+	}
+	return false
+}
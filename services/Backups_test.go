@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"hourly", false},
+		{"@daily", false},
+		{" Daily ", false},
+		{"*/15 * * * *", false},
+		{"30 2 * * 1-5", false},
+		{"0 0 1,15 * 7", false},
+		{"0 0 * * 0", false},
+		{"0 8-18/2 * 1-6 *", false},
+		{"", true},
+		{"weekly", true},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"*/0 * * * *", true},
+		{"5-1 * * * *", true},
+		{"a * * * *", true},
+	}
+	for _, tt := range tests {
+		_, err := parseSchedule(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSchedule(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBackupSpecDue(t *testing.T) {
+	at := func(value string) time.Time {
+		if value == "" {
+			return time.Time{}
+		}
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	// 2024-06-01 is a Saturday, 2024-06-13 a Thursday
+	tests := []struct {
+		name, spec, last, now string
+		want                  bool
+	}{
+		{"interval first run", "hourly", "", "2024-06-03 10:00", true},
+		{"interval not elapsed", "hourly", "2024-06-03 09:31", "2024-06-03 10:00", false},
+		{"interval elapsed", "hourly", "2024-06-03 09:00", "2024-06-03 10:00", true},
+		{"first run on a match", "30 2 * * *", "", "2024-06-03 02:30", true},
+		{"first run waits for a match", "30 2 * * *", "", "2024-06-03 02:31", false},
+		{"before the next match", "30 2 * * *", "2024-06-03 02:30", "2024-06-04 02:29", false},
+		{"on the next match", "30 2 * * *", "2024-06-03 02:30", "2024-06-04 02:30", true},
+		{"same minute as last", "30 2 * * *", "2024-06-04 02:30", "2024-06-04 02:30", false},
+		{"catch up a missed day", "30 2 * * *", "2024-06-03 02:30", "2024-06-05 10:00", true},
+		{"month boundary before", "0 3 1 * *", "2024-05-01 03:00", "2024-06-01 02:59", false},
+		{"month boundary catch up", "0 3 1 * *", "2024-05-01 03:00", "2024-06-01 03:05", true},
+		{"year boundary catch up", "0 3 1 * *", "2023-12-01 03:00", "2024-01-01 04:00", true},
+		{"month field waits", "0 0 1 1 *", "2024-01-01 00:00", "2024-12-31 23:59", false},
+		{"month field matches", "0 0 1 1 *", "2024-01-01 00:00", "2025-01-01 00:00", true},
+		{"dom or dow by dom", "0 0 13 * 5", "2024-06-12 00:00", "2024-06-13 00:00", true},
+		{"dom or dow by dow", "0 0 13 * 5", "2024-06-13 00:00", "2024-06-14 00:00", true},
+		{"dom or dow neither", "0 0 13 * 5", "2024-06-14 00:00", "2024-06-15 12:00", false},
+		{"dom only ignores dow", "0 0 13 * *", "2024-06-13 00:00", "2024-06-14 00:00", false},
+		{"dow only ignores dom", "0 0 * * 5", "2024-06-12 00:00", "2024-06-13 23:59", false},
+		{"sunday as 7", "0 9 * * 7", "2024-06-01 09:00", "2024-06-02 09:00", true},
+		{"sunday as 0", "0 9 * * 0", "2024-06-01 09:00", "2024-06-02 09:00", true},
+		{"sunday as 7 not saturday", "0 9 * * 7", "2024-05-31 09:00", "2024-06-01 23:59", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := parseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("parseSchedule(%q) error = %v", tt.spec, err)
+			}
+			if got := spec.due(at(tt.last), at(tt.now)); got != tt.want {
+				t.Errorf("%q due(%s, %s) = %v, want %v", tt.spec, tt.last, tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// Shared base images are tagged <baseImageRepository>:<runtime>-<version>-<arch>-<hash>,
+// where hash covers the base dockerfile, so they are rebuilt only when the
+// build context changes.
+const (
+	baseImageRepository = "contanize-base"
+	baseDockerfile      = "dockerfile"
+	workspaceDockerfile = "dockerfile.workspace"
+
+	labelKind    = "contanize.kind"
+	labelRuntime = "contanize.runtime"
+	labelVersion = "contanize.version"
+	labelArch    = "contanize.arch"
+	labelHash    = "contanize.hash"
+
+	kindBase      = "base"
+	kindWorkspace = "workspace"
+	// Images built from the Dockerfile of a devcontainer.json belong to a
+	// single workspace, unlike base images
+	kindDevcontainer = "devcontainer"
+)
+
+type BaseImage struct {
+	Tag     string `json:"tag"`
+	ImageID string `json:"image_id"`
+	Runtime string `json:"runtime"`
+	Version string `json:"version"`
+	Arch    string `json:"arch"`
+	Hash    string `json:"hash"`
+	Size    int64  `json:"size"`
+	Created string `json:"created"`
+}
+
+// baseImageTag returns the deterministic tag of the base image for the
+// given runtime, version and architecture built from the current context.
+func baseImageTag(runtime, version, arch string) (string, string, error) {
+	files, err := buildContextFiles()
+	if err != nil {
+		return "", "", err
+	}
+	dockerfile, ok := files[baseDockerfile]
+	if !ok {
+		return "", "", fmt.Errorf("build context has no %s", baseDockerfile)
+	}
+
+	h := sha256.New()
+	h.Write(dockerfile)
+	fmt.Fprintf(h, "\x00%s\x00%s\x00%s", runtime, version, arch)
+	hash := hex.EncodeToString(h.Sum(nil))[:12]
+
+	if version == "" {
+		version = "default"
+	}
+	tag := fmt.Sprintf("%s:%s-%s-%s-%s", baseImageRepository, runtime, version, arch, hash)
+	return tag, hash, nil
+}
+
+// ensureBaseImage returns the base image tag for opts, building the image
+// first if it is not cached yet or a refresh is requested.
+func (dc *DockerCreate) ensureBaseImage(ctx context.Context, opts CreateOptions, arch, buildID string, transcript *strings.Builder) (string, error) {
+	tag, hash, err := baseImageTag(opts.Runtime, opts.RuntimeVersion, arch)
+	if err != nil {
+		return "", err
+	}
+
+	if !opts.Refresh {
+		if _, _, err := dc.cli.ImageInspectWithRaw(ctx, tag); err == nil {
+			transcript.WriteString(fmt.Sprintf("Using cached base image %s\n", tag))
+			return tag, nil
+		} else if !client.IsErrNotFound(err) {
+			return "", fmt.Errorf("failed to inspect base image: %v", err)
+		}
+	}
+
+	goArch := goTarballArch(arch)
+	buildOptions := types.ImageBuildOptions{
+		Dockerfile: baseDockerfile,
+		Tags:       []string{tag},
+		BuildArgs: map[string]*string{
+			"RUNTIME":         &opts.Runtime,
+			"RUNTIME_VERSION": &opts.RuntimeVersion,
+			"TARGETARCH":      &arch,
+			"GO_ARCH":         &goArch,
+		},
+		Labels: map[string]string{
+			labelKind:    kindBase,
+			labelRuntime: opts.Runtime,
+			labelVersion: opts.RuntimeVersion,
+			labelArch:    arch,
+			labelHash:    hash,
+		},
+		Remove:     true,
+		NoCache:    opts.Refresh,
+		PullParent: opts.Refresh,
+		Platform:   opts.Platform,
+	}
+	if err := dc.buildImage(ctx, buildOptions, buildID, transcript); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// buildImage builds an image from the merged build context, streaming its
+// progress and appending the output to transcript.
+func (dc *DockerCreate) buildImage(ctx context.Context, buildOptions types.ImageBuildOptions, buildID string, transcript *strings.Builder) error {
+	buf, err := buildContextTar()
+	if err != nil {
+		return err
+	}
+
+	response, err := dc.cli.ImageBuild(ctx, buf, buildOptions)
+	if err != nil {
+		return fmt.Errorf("failed to build Docker image: %v", err)
+	}
+	defer response.Body.Close()
+
+	output, err := dc.readBuildOutput(buildID, response.Body)
+	transcript.WriteString(output)
+	return err
+}
+
+// ListBaseImages returns the cached shared base images.
+func ListBaseImages() ([]BaseImage, error) {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	images, err := cli.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", labelKind+"="+kindBase)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list base images: %v", err)
+	}
+
+	var baseImages []BaseImage
+	for _, img := range images {
+		tag := "<none>"
+		for _, repoTag := range img.RepoTags {
+			if strings.HasPrefix(repoTag, baseImageRepository+":") {
+				tag = repoTag
+				break
+			}
+		}
+		baseImages = append(baseImages, BaseImage{
+			Tag:     tag,
+			ImageID: img.ID,
+			Runtime: img.Labels[labelRuntime],
+			Version: img.Labels[labelVersion],
+			Arch:    img.Labels[labelArch],
+			Hash:    img.Labels[labelHash],
+			Size:    img.Size,
+			Created: time.Unix(img.Created, 0).Format(time.RFC3339),
+		})
+	}
+	return baseImages, nil
+}
+
+// ClearBaseImages removes every cached base image and returns the removed
+// references. Base images are removed by tag, so the layers of a base image
+// still used by a workspace stay on disk until that workspace is removed.
+func ClearBaseImages() ([]string, error) {
+	baseImages, err := ListBaseImages()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	var removed []string
+	for _, baseImage := range baseImages {
+		ref := baseImage.Tag
+		if ref == "<none>" {
+			ref = baseImage.ImageID
+		}
+		if _, err := cli.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil {
+			return removed, fmt.Errorf("failed to remove base image %s: %v", ref, err)
+		}
+		removed = append(removed, ref)
+	}
+	return removed, nil
+}
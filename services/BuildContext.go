@@ -0,0 +1,162 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// embeddedContext is the build context shipped inside the binary. It is
+// set once at startup by SetBuildContext.
+var embeddedContext fs.FS
+
+// SetBuildContext registers the build context embedded in the binary.
+func SetBuildContext(fsys fs.FS) {
+	embeddedContext = fsys
+}
+
+// ConfigDir returns the Contanize directory under the user config
+// directory, creating it if needed.
+func ConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config directory: %v", err)
+	}
+	dir = filepath.Join(dir, "contanize")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %v", err)
+	}
+	return dir, nil
+}
+
+// BuildOverrideDir returns the directory whose files replace or extend the
+// embedded build context, e.g. a custom dockerfile or extra templates.
+func BuildOverrideDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "build"), nil
+}
+
+// buildContextFiles returns the merged view of the build context, keyed by
+// slash separated path. Files in the override directory win over the
+// embedded ones.
+func buildContextFiles() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	if embeddedContext != nil {
+		err := fs.WalkDir(embeddedContext, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(embeddedContext, name)
+			if err != nil {
+				return err
+			}
+			files[name] = data
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading embedded build context: %v", err)
+		}
+	}
+
+	overrideDir, err := BuildOverrideDir()
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.Walk(overrideDir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(overrideDir, file)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relPath)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading build overrides: %v", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("build context is empty")
+	}
+	return files, nil
+}
+
+// buildContextGlob returns the merged build context files matching pattern.
+func buildContextGlob(pattern string) (map[string][]byte, error) {
+	files, err := buildContextFiles()
+	if err != nil {
+		return nil, err
+	}
+	matches := make(map[string][]byte)
+	for name, data := range files {
+		if ok, _ := path.Match(pattern, name); ok {
+			matches[name] = data
+		}
+	}
+	return matches, nil
+}
+
+// buildContextTar assembles the merged build context into an in-memory tarball.
+func buildContextTar() (*bytes.Buffer, error) {
+	files, err := buildContextFiles()
+	if err != nil {
+		return nil, err
+	}
+	return tarFiles(files)
+}
+
+func tarFiles(files map[string][]byte) (*bytes.Buffer, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		mode := int64(0644)
+		if strings.HasSuffix(name, ".sh") {
+			mode = 0755
+		}
+		header := &tar.Header{
+			Name:    name,
+			Mode:    mode,
+			Size:    int64(len(files[name])),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("error creating tarball: %v", err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, fmt.Errorf("error creating tarball: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("error closing tar writer: %v", err)
+	}
+	return &buf, nil
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"gopkg.in/yaml.v3"
+)
+
+// A workspace bundle is a gzipped tarball holding the manifest, the saved
+// workspace image and optionally a tarball of the workspace folder, in that
+// order so that it can be imported in a single pass.
+const (
+	bundleVersion      = 1
+	bundleManifestFile = "workspace.yaml"
+	bundleImageFile    = "image.tar"
+	bundleFilesFile    = "files.tar"
+)
+
+type bundleManifest struct {
+	Version int `yaml:"version"`
+	// Image is the reference the workspace image was saved under.
+	Image     string        `yaml:"image"`
+	Files     bool          `yaml:"files"`
+	Workspace ContainerInfo `yaml:"workspace"`
+	// Mounts are the extra mounts of the workspace, such as those of a
+	// devcontainer.json. Volumes are recreated empty on import.
+	Mounts []bundleMount `yaml:"mounts,omitempty"`
+}
+
+type bundleMount struct {
+	Type     mount.Type `yaml:"type"`
+	Source   string     `yaml:"source,omitempty"`
+	Target   string     `yaml:"target"`
+	ReadOnly bool       `yaml:"readonly,omitempty"`
+}
+
+// ExportWorkspace writes a bundle of a workspace to filename. The current
+// state of the container is committed first. With includeFiles set, the
+// workspace folder is packed into the bundle as well.
+func ExportWorkspace(ctx context.Context, containerName, filename string, includeFiles bool) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	info, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %v", err)
+	}
+	record, err := findRecord(info.ID)
+	if err != nil {
+		return err
+	}
+
+	// The random suffix keeps exports started in the same millisecond apart
+	ref := fmt.Sprintf("%s:bundle-%s-%s", imageRepository(info.Config.Image), time.Now().Format(snapshotTimeFormat), generateRandomString(6))
+	if _, err := cli.ContainerCommit(ctx, info.ID, container.CommitOptions{Reference: ref}); err != nil {
+		return fmt.Errorf("failed to commit container: %v", err)
+	}
+	defer removeBundleImage(cli, ref, false)
+
+	// Tar headers need the size up front, so the image and the files are
+	// spooled to disk first
+	imageFile, err := os.CreateTemp("", "contanize-image-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(imageFile.Name())
+	defer imageFile.Close()
+
+	saved, err := cli.ImageSave(ctx, []string{ref})
+	if err != nil {
+		return fmt.Errorf("failed to save image: %v", err)
+	}
+	_, err = io.Copy(imageFile, saved)
+	saved.Close()
+	if err != nil {
+		return fmt.Errorf("failed to save image: %v", err)
+	}
+
+	var filesFile *os.File
+	if includeFiles && record.Volume != "" {
+		filesFile, err = os.CreateTemp("", "contanize-files-*.tar")
+		if err != nil {
+			return fmt.Errorf("failed to create temp file: %v", err)
+		}
+		defer os.Remove(filesFile.Name())
+		defer filesFile.Close()
+		if err := writeDirectoryTar(filesFile, record.Volume); err != nil {
+			return err
+		}
+	}
+
+	workspace := *record
+	workspace.ContainerID = ""
+	workspace.BuildLog = ""
+	workspace.Snapshots = nil
+	var mounts []bundleMount
+	for _, m := range info.HostConfig.Mounts {
+		mounts = append(mounts, bundleMount{Type: m.Type, Source: m.Source, Target: m.Target, ReadOnly: m.ReadOnly})
+	}
+	manifest, err := yaml.Marshal(bundleManifest{
+		Version:   bundleVersion,
+		Image:     ref,
+		Files:     filesFile != nil,
+		Workspace: workspace,
+		Mounts:    mounts,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling YAML: %v", err)
+	}
+
+	out, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+	defer out.Close()
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+
+	if err := writeTarEntry(tw, bundleManifestFile, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, bundleImageFile, imageFile); err != nil {
+		return err
+	}
+	if filesFile != nil {
+		if err := writeTarFile(tw, bundleFilesFile, filesFile); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error closing tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("error closing gzip writer: %v", err)
+	}
+	return out.Close()
+}
+
+// ImportWorkspace loads a bundle written by ExportWorkspace and creates a
+// workspace called name from it, bound to folder. An empty name keeps the
+// name of the exported workspace. Files packed into the bundle are
+// extracted into folder. Host ports are allocated afresh, and bind mounts
+// whose source does not exist on this host are dropped. It returns the ID
+// of the new container.
+func ImportWorkspace(ctx context.Context, filename, name, folder string) (containerID string, err error) {
+	if folder == "" {
+		return "", fmt.Errorf("a folder for the workspace is required")
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	in, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", filename, err)
+	}
+	defer in.Close()
+	gr, err := gzip.NewReader(in)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a workspace bundle: %v", filename, err)
+	}
+	tr := tar.NewReader(gr)
+
+	var manifest *bundleManifest
+	imageLoaded := false
+	// The loaded image is removed again unless a container was created
+	// from it
+	var loadedImages []string
+	defer func() {
+		if containerID != "" {
+			return
+		}
+		for _, ref := range loadedImages {
+			removeBundleImage(cli, ref, true)
+		}
+	}()
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading bundle: %v", err)
+		}
+
+		switch header.Name {
+		case bundleManifestFile:
+			manifest = &bundleManifest{}
+			if err := yaml.NewDecoder(tr).Decode(manifest); err != nil {
+				return "", fmt.Errorf("error reading bundle manifest: %v", err)
+			}
+			if manifest.Version > bundleVersion {
+				return "", fmt.Errorf("%s was written by a newer version of Contanize", filename)
+			}
+			if name == "" {
+				name = manifest.Workspace.Name
+			}
+			name = strings.ToLower(name)
+			if _, err := cli.ContainerInspect(ctx, name); err == nil {
+				return "", fmt.Errorf("container with name %s already exists", name)
+			}
+		case bundleImageFile:
+			if manifest == nil {
+				return "", fmt.Errorf("%s has no manifest", filename)
+			}
+			loadedImages = append(loadedImages, manifest.Image)
+			resp, err := cli.ImageLoad(ctx, tr, true)
+			if err != nil {
+				return "", fmt.Errorf("failed to load image: %v", err)
+			}
+			err = jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil)
+			resp.Body.Close()
+			if err != nil {
+				return "", fmt.Errorf("failed to load image: %v", err)
+			}
+			imageLoaded = true
+		case bundleFilesFile:
+			if err := extractTar(tr, folder); err != nil {
+				return "", err
+			}
+		}
+	}
+	if manifest == nil || !imageLoaded {
+		return "", fmt.Errorf("%s is not a complete workspace bundle", filename)
+	}
+
+	imageName := fmt.Sprintf("%s-%s", name, generateRandomString(8))
+	if err := cli.ImageTag(ctx, manifest.Image, imageName); err != nil {
+		return "", fmt.Errorf("failed to tag image: %v", err)
+	}
+	loadedImages = append(loadedImages, imageName)
+	removeBundleImage(cli, manifest.Image, false)
+
+	var mounts []mount.Mount
+	for _, m := range manifest.Mounts {
+		if m.Type == mount.TypeBind {
+			if _, err := os.Stat(m.Source); err != nil {
+				log.Printf("Dropping mount of %s at %s: %v", m.Source, m.Target, err)
+				continue
+			}
+		}
+		mounts = append(mounts, mount.Mount{Type: m.Type, Source: m.Source, Target: m.Target, ReadOnly: m.ReadOnly})
+	}
+
+	reservation, err := reservePorts(ctx, cli, name)
+	if err != nil {
+		return "", err
+	}
+	defer reservation.Release()
+
+	internalPorts := make([]string, 0, len(manifest.Workspace.Ports))
+	for internalPort := range manifest.Workspace.Ports {
+		internalPorts = append(internalPorts, internalPort)
+	}
+	portMappings, portBindings, exposedPorts, err := reservation.Bind(internalPorts)
+	if err != nil {
+		return "", err
+	}
+
+	config := &container.Config{
+		Image:        imageName,
+		ExposedPorts: exposedPorts,
+	}
+	if manifest.Workspace.Template != "" {
+		config.Cmd = []string{manifest.Workspace.Template}
+	}
+	resp, err := cli.ContainerCreate(ctx, config, &container.HostConfig{
+		PortBindings: portBindings,
+		Binds:        []string{folder + ":" + workspaceFolder},
+		Mounts:       mounts,
+		Privileged:   true,
+	}, nil, nil, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %v", err)
+	}
+	reservation.Release()
+
+	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return resp.ID, fmt.Errorf("failed to start container: %v", err)
+	}
+
+	record := manifest.Workspace
+	record.ContainerID = resp.ID
+	record.Name = name
+	record.Image = imageName
+	record.Ports = portMappings
+	record.Volume = folder
+	if err := recordContainer(record); err != nil {
+		return resp.ID, err
+	}
+	return resp.ID, nil
+}
+
+// removeBundleImage removes an image reference used while exporting or
+// importing a bundle, logging the failure if it cannot be removed.
+func removeBundleImage(cli *client.Client, ref string, pruneChildren bool) {
+	_, err := cli.ImageRemove(context.Background(), ref, image.RemoveOptions{PruneChildren: pruneChildren})
+	if err != nil && !client.IsErrNotFound(err) {
+		log.Printf("Failed to remove image %s: %v", ref, err)
+	}
+}
+
+func writeTarFile(tw *tar.Writer, name string, f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeTarEntry(tw, name, fi.Size(), f)
+}
+
+func writeTarEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error creating bundle: %v", err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("error creating bundle: %v", err)
+	}
+	return nil
+}
+
+// extractTar unpacks the directories and regular files of a tarball into
+// dir, refusing entries that would land outside of it.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error extracting files: %v", err)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if rel, err := filepath.Rel(dir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("bundle entry %s is outside of the workspace folder", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(header.Mode)|0700); err != nil {
+				return fmt.Errorf("error extracting files: %v", err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("error extracting files: %v", err)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+			if err != nil {
+				return fmt.Errorf("error extracting files: %v", err)
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("error extracting files: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractTar(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr bool
+	}{
+		{"files", []string{"main.go", "src/app.js"}, false},
+		{"inner dotdot", []string{"src/../main.go"}, false},
+		{"parent", []string{"../evil"}, true},
+		{"nested parent", []string{"src/../../evil"}, true},
+		{"parent dir itself", []string{".."}, true},
+		{"after a valid entry", []string{"main.go", "../../etc/evil"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, name := range tt.entries {
+				if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 2, Typeflag: tar.TypeReg}); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := tw.Write([]byte("ok")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			root := t.TempDir()
+			dir := filepath.Join(root, "workspace")
+			err := extractTar(&buf, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractTar() error = %v, want error %v", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(filepath.Join(root, "evil")); statErr == nil {
+				t.Error("extractTar() wrote outside of the workspace folder")
+			}
+			if !tt.wantErr {
+				for _, name := range tt.entries {
+					if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+						t.Errorf("entry %s was not extracted: %v", name, err)
+					}
+				}
+			}
+		})
+	}
+}
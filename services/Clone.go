@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+)
+
+// CloneWorkspace creates a new workspace called newName from the current
+// state of source. The clone is bound to newFolder, or shares the folder of
+// source if newFolder is empty. With copyFiles set, the files of the source
+// folder are copied into newFolder first. The clone gets its own host
+// ports, and its store entry records source as its origin. It returns the
+// ID of the new container.
+func CloneWorkspace(ctx context.Context, source, newName, newFolder string, copyFiles bool) (string, error) {
+	newName = strings.ToLower(strings.TrimSpace(newName))
+	if newName == "" {
+		return "", fmt.Errorf("a name for the clone is required")
+	}
+	if copyFiles && newFolder == "" {
+		return "", fmt.Errorf("a folder to copy the files into is required")
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	info, err := cli.ContainerInspect(ctx, source)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %v", err)
+	}
+	if _, err := cli.ContainerInspect(ctx, newName); err == nil {
+		return "", fmt.Errorf("container with name %s already exists", newName)
+	}
+	record, err := findRecord(info.ID)
+	if err != nil {
+		return "", err
+	}
+
+	folder := record.Volume
+	if newFolder != "" {
+		folder = newFolder
+	}
+	if copyFiles && record.Volume != "" {
+		inside, err := isWithin(newFolder, record.Volume)
+		if err != nil {
+			return "", err
+		}
+		if inside {
+			return "", fmt.Errorf("the folder of the clone must be outside of %s", record.Volume)
+		}
+		if err := copyDirectory(record.Volume, newFolder); err != nil {
+			return "", err
+		}
+	}
+
+	imageName := fmt.Sprintf("%s-%s", newName, generateRandomString(8))
+	if _, err := cli.ContainerCommit(ctx, info.ID, container.CommitOptions{Reference: imageName}); err != nil {
+		return "", fmt.Errorf("failed to commit container: %v", err)
+	}
+
+	reservation, err := reservePorts(ctx, cli, newName)
+	if err != nil {
+		return "", err
+	}
+	defer reservation.Release()
+
+	var internalPorts []string
+	for port := range info.HostConfig.PortBindings {
+		internalPorts = append(internalPorts, port.Port())
+	}
+	portMappings, portBindings, exposedPorts, err := reservation.Bind(internalPorts)
+	if err != nil {
+		return "", err
+	}
+
+	config := *info.Config
+	config.Image = imageName
+	config.Hostname = ""
+	for port := range config.ExposedPorts {
+		exposedPorts[port] = struct{}{}
+	}
+	config.ExposedPorts = exposedPorts
+
+	hostConfig := *info.HostConfig
+	hostConfig.PortBindings = portBindings
+	hostConfig.Binds = nil
+	for _, bind := range info.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) > 1 && parts[1] == workspaceFolder {
+			parts[0] = folder
+		}
+		hostConfig.Binds = append(hostConfig.Binds, strings.Join(parts, ":"))
+	}
+	// Volumes belong to the source, the clone only keeps bind mounts
+	hostConfig.Mounts = nil
+	for _, m := range info.HostConfig.Mounts {
+		if m.Type == mount.TypeBind {
+			hostConfig.Mounts = append(hostConfig.Mounts, m)
+		}
+	}
+
+	resp, err := cli.ContainerCreate(ctx, &config, &hostConfig, nil, nil, newName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %v", err)
+	}
+	reservation.Release()
+
+	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return resp.ID, fmt.Errorf("failed to start container: %v", err)
+	}
+
+	clone := *record
+	clone.ContainerID = resp.ID
+	clone.Name = newName
+	clone.Image = imageName
+	clone.Ports = portMappings
+	clone.Volume = folder
+	clone.Origin = record.Name
+	clone.BuildLog = ""
+	clone.Snapshots = nil
+	if err := recordContainer(clone); err != nil {
+		return resp.ID, err
+	}
+	return resp.ID, nil
+}
+
+// copyDirectory copies the files and directories under src into dst.
+func copyDirectory(src, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dst, err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeDirectoryTar(pw, src))
+	}()
+	err := extractTar(pr, dst)
+	pr.CloseWithError(err)
+	return err
+}
+
+// isWithin reports whether path is dir or lies below it, once both are
+// made absolute and their symlinks resolved. path need not exist yet.
+func isWithin(path, dir string) (bool, error) {
+	path, err := resolvePath(path)
+	if err != nil {
+		return false, err
+	}
+	dir, err = resolvePath(dir)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false, nil
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))), nil
+}
+
+// resolvePath makes path absolute and resolves the symlinks of the part of
+// it that exists.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", path, err)
+	}
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to resolve %s: %v", path, err)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
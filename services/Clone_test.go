@@ -0,0 +1,44 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsWithin(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(src, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, path string
+		want       bool
+	}{
+		{"same folder", src, true},
+		{"trailing slash", src + string(filepath.Separator), true},
+		{"existing subfolder", filepath.Join(src, "sub"), true},
+		{"new subfolder", filepath.Join(src, "clone", "deeper"), true},
+		{"through a symlink", filepath.Join(root, "link", "clone"), true},
+		{"dotdot back inside", filepath.Join(root, "other", "..", "src", "clone"), true},
+		{"sibling", filepath.Join(root, "src-clone"), false},
+		{"parent", root, false},
+		{"new sibling folder", filepath.Join(root, "other", "clone"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isWithin(tt.path, src)
+			if err != nil {
+				t.Fatalf("isWithin(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("isWithin(%q, %q) = %v, want %v", tt.path, src, got, tt.want)
+			}
+		})
+	}
+}
@@ -17,35 +17,212 @@ type ContainerInfo struct {
 	Ports       map[string]string `yaml:"ports"`
 	Volume      string            `yaml:"volume"`
 	Template    string            `yaml:"template"`
+	Technology  string            `yaml:"technology,omitempty"`
+	Version     string            `yaml:"version,omitempty"`
+	Arch        string            `yaml:"arch,omitempty"`
+	// Source is "devcontainer" for workspaces created from a
+	// devcontainer.json, whose path is kept in Devcontainer.
+	Source       string `yaml:"source,omitempty"`
+	Devcontainer string `yaml:"devcontainer,omitempty"`
+	BuildLog     string `yaml:"build_log,omitempty"`
+	// Origin is the name of the workspace this one was cloned from.
+	Origin    string     `yaml:"origin,omitempty"`
+	Snapshots []Snapshot `yaml:"snapshots,omitempty"`
 }
 
 type Database []ContainerInfo
 
+// storeVersion is the schema version written to the store. Stores without a
+// version are the plain list of entries written by older releases. Version 2
+// adds backup schedules, which releases reading version 1 would drop.
+const storeVersion = 2
+
+type storeFile struct {
+	Version    int              `yaml:"version"`
+	Containers Database         `yaml:"containers"`
+	Backups    []BackupSchedule `yaml:"backups,omitempty"`
+}
+
+// Transaction holds an exclusive lock on the store from NewTransaction
+// until rollback, so every transaction must be rolled back once done, even
+// after a commit.
 type Transaction struct {
+	store    *storeFile
 	db       *Database
 	filename string
 	tempFile string
+	lock     *os.File
 }
 
-func NewTransaction(filename string) (*Transaction, error) {
-	if err := ensureFileExists(filename); err != nil {
-		return nil, fmt.Errorf("error ensuring file exists: %v", err)
+// StorePath returns the path of the workspace store under the config
+// directory.
+func StorePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(dir, "info.yaml"), nil
+}
 
-	db, err := readDatabase(filename)
+// openStore begins a transaction on the workspace store, migrating the
+// store of older releases on first use. The migration runs with the store
+// locked, so that two instances starting together cannot both migrate.
+func openStore() (*Transaction, error) {
+	filename, err := StorePath()
 	if err != nil {
 		return nil, err
 	}
+	transaction, err := newTransaction(filename, migrateLegacyStore)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	return transaction, nil
+}
+
+// recordContainer adds an entry to the workspace store, replacing the one
+// the reconciler may already have adopted for the same container.
+func recordContainer(info ContainerInfo) error {
+	transaction, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer transaction.rollback()
+	if !transaction.UpdateEntry(info.ContainerID, info) {
+		transaction.CreateEntry(info)
+	}
+	if err := transaction.commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
 
+// findRecord returns a copy of the store entry of a container.
+func findRecord(id string) (*ContainerInfo, error) {
+	transaction, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.rollback()
+	record, ok := transaction.ReadEntryById(id)
+	if !ok {
+		return nil, fmt.Errorf("container %s has no record in the store", shortID(id))
+	}
+	return record, nil
+}
+
+// updateRecord applies update to the store entry of the workspace called
+// name.
+func updateRecord(name string, update func(*ContainerInfo)) error {
+	transaction, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer transaction.rollback()
+	record, ok := transaction.ReadEntry(name)
+	if !ok {
+		return fmt.Errorf("workspace %s has no record in the store", name)
+	}
+	updated := *record
+	update(&updated)
+	transaction.UpdateEntry(record.ContainerID, updated)
+	if err := transaction.commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// ForgetContainer removes the store entry of a removed container.
+func ForgetContainer(id string) error {
+	transaction, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer transaction.rollback()
+	if !transaction.DeleteEntry(id) {
+		return nil
+	}
+	if err := transaction.commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// migrateLegacyStore copies the store that older releases kept in the
+// working directory to filename, unless filename already exists. Those
+// stores could hold several entries with the same name, only the last one
+// written is kept.
+func migrateLegacyStore(filename string) error {
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		return err
+	}
 	dir, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		return nil
 	}
-	tempFile := filepath.Join(dir, fmt.Sprintf("%s.tmp", filepath.Base(filename)))
+	legacy := filepath.Join(dir, "info.yaml")
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+
+	store, err := readStore(legacy)
+	if err != nil {
+		return fmt.Errorf("error migrating %s: %v", legacy, err)
+	}
+	latest := make(map[string]int)
+	for i, info := range store.Containers {
+		latest[info.Name] = i
+	}
+	var migrated Database
+	for i, info := range store.Containers {
+		if latest[info.Name] == i {
+			migrated = append(migrated, info)
+		}
+	}
+	store.Containers = migrated
+
+	if err := writeStore(filename, store); err != nil {
+		return fmt.Errorf("error migrating %s: %v", legacy, err)
+	}
+	log.Printf("Migrated %d entries from %s to %s", len(migrated), legacy, filename)
+	return nil
+}
+
+func NewTransaction(filename string) (*Transaction, error) {
+	return newTransaction(filename, nil)
+}
+
+// newTransaction begins a transaction on filename. setup, if given, runs
+// once the lock is held and before the store is read.
+func newTransaction(filename string, setup func(filename string) error) (*Transaction, error) {
+	lock, err := lockFile(filename + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("error locking store: %v", err)
+	}
+
+	if setup != nil {
+		if err := setup(filename); err != nil {
+			unlockFile(lock)
+			return nil, err
+		}
+	}
+
+	if err := ensureFileExists(filename); err != nil {
+		unlockFile(lock)
+		return nil, fmt.Errorf("error ensuring file exists: %v", err)
+	}
+
+	store, err := readStore(filename)
+	if err != nil {
+		unlockFile(lock)
+		return nil, err
+	}
+
 	return &Transaction{
-		db:       db,
+		store:    store,
+		db:       &store.Containers,
 		filename: filename,
-		tempFile: tempFile,
+		tempFile: filename + ".tmp",
+		lock:     lock,
 	}, nil
 }
 
@@ -53,15 +230,14 @@ func ensureFileExists(filename string) error {
 	_, err := os.Stat(filename)
 	if os.IsNotExist(err) {
 		log.Printf("File %s does not exist. Creating it with an empty database.", filename)
-		emptyDB := &Database{}
-		return writeDatabase(filename, emptyDB)
+		return writeStore(filename, &storeFile{Containers: Database{}})
 	}
 	return err
 }
 
 func (t *Transaction) commit() error {
 	// Write to temp file
-	if err := writeDatabase(t.tempFile, t.db); err != nil {
+	if err := writeStore(t.tempFile, t.store); err != nil {
 		return fmt.Errorf("failed to write to temp file: %v", err)
 	}
 
@@ -73,27 +249,55 @@ func (t *Transaction) commit() error {
 	return nil
 }
 
+// rollback discards uncommitted changes and releases the store lock.
 func (t *Transaction) rollback() {
 	os.Remove(t.tempFile)
+	if t.lock != nil {
+		unlockFile(t.lock)
+		t.lock = nil
+	}
 }
 
-func readDatabase(filename string) (*Database, error) {
+// readStore reads a store file, accepting both the versioned format and the
+// plain list of entries written by older releases.
+func readStore(filename string) (*storeFile, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file: %v", err)
 	}
 
-	var db Database
-	err = yaml.Unmarshal(data, &db)
-	if err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("error unmarshaling YAML: %v", err)
 	}
+	if len(doc.Content) == 0 {
+		return &storeFile{Containers: Database{}}, nil
+	}
+
+	if doc.Content[0].Kind == yaml.SequenceNode {
+		var db Database
+		if err := doc.Decode(&db); err != nil {
+			return nil, fmt.Errorf("error unmarshaling YAML: %v", err)
+		}
+		return &storeFile{Containers: db}, nil
+	}
 
-	return &db, nil
+	var store storeFile
+	if err := doc.Decode(&store); err != nil {
+		return nil, fmt.Errorf("error unmarshaling YAML: %v", err)
+	}
+	if store.Version > storeVersion {
+		return nil, fmt.Errorf("%s was written by a newer version of Contanize (schema %d)", filename, store.Version)
+	}
+	if store.Containers == nil {
+		store.Containers = Database{}
+	}
+	return &store, nil
 }
 
-func writeDatabase(filename string, db *Database) error {
-	data, err := yaml.Marshal(db)
+func writeStore(filename string, store *storeFile) error {
+	store.Version = storeVersion
+	data, err := yaml.Marshal(store)
 	if err != nil {
 		return fmt.Errorf("error marshaling YAML: %v", err)
 	}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadStore(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		containers []string
+		backups    int
+		wantErr    bool
+	}{
+		{"empty file", "", nil, 0, false},
+		{"legacy list", "- name: web\n  image: web-1\n- name: api\n  image: api-1\n", []string{"web", "api"}, 0, false},
+		{"legacy empty list", "[]\n", nil, 0, false},
+		{"version 1", "version: 1\ncontainers:\n- name: web\n", []string{"web"}, 0, false},
+		{"version 2", "version: 2\ncontainers:\n- name: db\nbackups:\n- container: db\n  schedule: daily\n  retention: 7\n", []string{"db"}, 1, false},
+		{"no containers", "version: 2\n", nil, 0, false},
+		{"newer version", "version: 99\ncontainers: []\n", nil, 0, true},
+		{"invalid", "containers: [\n", nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "info.yaml")
+			if err := os.WriteFile(filename, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			store, err := readStore(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readStore() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if store.Containers == nil {
+				t.Error("readStore() returned nil containers")
+			}
+			var names []string
+			for _, info := range store.Containers {
+				names = append(names, info.Name)
+			}
+			if len(names) != len(tt.containers) {
+				t.Fatalf("readStore() containers = %v, want %v", names, tt.containers)
+			}
+			for i := range names {
+				if names[i] != tt.containers[i] {
+					t.Errorf("readStore() containers = %v, want %v", names, tt.containers)
+					break
+				}
+			}
+			if len(store.Backups) != tt.backups {
+				t.Errorf("readStore() backups = %d, want %d", len(store.Backups), tt.backups)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyStore(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyDir := t.TempDir()
+	if err := os.Chdir(legacyDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	legacy := "- name: web\n  image: web-1\n- name: api\n  image: api-1\n- name: web\n  image: web-2\n"
+	if err := os.WriteFile(filepath.Join(legacyDir, "info.yaml"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "info.yaml")
+	if err := migrateLegacyStore(filename); err != nil {
+		t.Fatalf("migrateLegacyStore() error = %v", err)
+	}
+	store, err := readStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Version != storeVersion {
+		t.Errorf("migrated store version = %d, want %d", store.Version, storeVersion)
+	}
+	images := make(map[string]string)
+	for _, info := range store.Containers {
+		images[info.Name] = info.Image
+	}
+	if len(store.Containers) != 2 || images["api"] != "api-1" || images["web"] != "web-2" {
+		t.Errorf("migrated containers = %+v, want api-1 and the last web-2", store.Containers)
+	}
+
+	// An existing store is never replaced
+	if err := os.WriteFile(filepath.Join(legacyDir, "info.yaml"), []byte("- name: other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateLegacyStore(filename); err != nil {
+		t.Fatalf("migrateLegacyStore() error = %v", err)
+	}
+	if store, err := readStore(filename); err != nil || len(store.Containers) != 2 {
+		t.Errorf("migrateLegacyStore() replaced an existing store")
+	}
+}
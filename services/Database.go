@@ -1,14 +1,25 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"net"
 	"os"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
-	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-connections/nat"
 )
 
 var DesktopEnv string
@@ -17,86 +28,74 @@ func init() {
 	DesktopEnv = getDesktopEnvironment()
 }
 
-// Postgres
-func RunPostgresContainer(user, password, db, containerName string) (string, error) {
-	freeport, err := findFreePort("5432")
-	if err != nil {
-		log.Fatal("Error while finding port", err)
-	}
-	command := fmt.Sprintf(`docker run -d \
-	--label createdBy=Contanize \
-	--label type=Database \
-	--label dbuser=%s \
-	--label db=postgres \
-	-e POSTGRES_USER=%s \
-	-e POSTGRES_PASSWORD=%s \
-	-e POSTGRES_DB=%s \
-	-e POSTGRES_ROOT_PASSWORD=root \
-	-p %s:5432 \
-	--name %s \
-	--restart unless-stopped \
-	--health-cmd "pg_isready -U %s -d %s" \
-	--health-interval 10s \
-	--health-timeout 5s \
-	--health-retries 5 \
-	postgres:alpine`,
-		user, user, password, db, freeport, containerName, user, db)
-
-	cmd := exec.Command("sh", "-c", command)
-
-	output, err := cmd.CombinedOutput()
+// CreateDatabase creates and starts a container running the engine with
+// the given ID, and returns the ID of the container.
+func CreateDatabase(engineID string, credentials DatabaseCredentials, containerName string) (string, error) {
+	engine, err := FindDatabaseEngine(engineID)
 	if err != nil {
-		return "", fmt.Errorf("failed to run Docker command: %v\nOutput: %s", err, output)
+		return "", err
 	}
-
-	fmt.Printf("PostgreSQL container started successfully. Container ID: %s", output)
-	return string(output), nil
+	info := engine.Info()
+	if !info.Users {
+		credentials.User = ""
+	}
+	if !info.Databases {
+		credentials.Database = ""
+	}
+	if err := validateDatabaseInput(info, credentials, containerName); err != nil {
+		return "", err
+	}
+	return runDatabaseContainer(context.Background(), containerName, databaseSpec{
+		image:   engine.Image(),
+		port:    info.Port,
+		dataDir: engine.DataDir(),
+		env:     engine.Env(credentials),
+		cmd:     engine.Cmd(credentials),
+		labels: map[string]string{
+			"db":     info.ID,
+			"dbuser": credentials.User,
+			"dbname": credentials.Database,
+			"dbpass": credentials.Password,
+		},
+		healthcheck: engine.HealthCheck(credentials),
+	})
 }
 
-func ConnectToPostgres(contName, dbuser string) {
-	cmd := fmt.Sprintf("docker exec -it %s psql -U %s", contName, dbuser)
-	openTerminal(cmd)
+// DatabaseCredentialsFromLabels reads the credentials a database container
+// was created with back from its labels.
+func DatabaseCredentialsFromLabels(labels map[string]string) DatabaseCredentials {
+	return DatabaseCredentials{
+		User:     labels["dbuser"],
+		Password: labels["dbpass"],
+		Database: labels["dbname"],
+	}
 }
 
-// MongoDB
-func RunMongoContainer(user, password, db, containerName string) (string, error) {
-	freeport, err := findFreePort("27017")
+// OpenDatabaseTerminal opens a terminal running the client of the engine
+// inside the database container contName.
+func OpenDatabaseTerminal(contName string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		log.Fatal("Error while finding port", err)
-	}
-
-	command := fmt.Sprintf(`docker run -d \
-        --label createdBy=Contanize \
-        --label type=Database \
-        --label dbuser=%s \
-        --label db=mongo \
-		--label dbpass=%s \
-        -e MONGO_INITDB_ROOT_USERNAME=%s \
-        -e MONGO_INITDB_ROOT_PASSWORD=%s \
-        -e MONGO_INITDB_DATABASE=%s \
-        -p %s:27017 \
-        --name %s \
-        --restart unless-stopped \
-        --health-cmd "mongo --eval 'db.runCommand({ ping: 1 })'" \
-        --health-interval 10s \
-        --health-timeout 5s \
-        --health-retries 5 \
-        mongo:latest`,
-		user, password, user, password, db, freeport, containerName)
-
-	cmd := exec.Command("sh", "-c", command)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("failed to run Docker command: %v\nOutput: %s", err, output)
+		return fmt.Errorf("failed to create Docker client: %v", err)
 	}
+	defer cli.Close()
 
-	fmt.Printf("MongoDB container started successfully. Container ID: %s", output)
-	return string(output), nil
-}
+	info, err := cli.ContainerInspect(context.Background(), contName)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %v", err)
+	}
+	engine, err := FindDatabaseEngine(info.Config.Labels["db"])
+	if err != nil {
+		return err
+	}
 
-func ConnectToMongo(contName, dbuser string) {
-	cmd := fmt.Sprintf("docker exec -it %s mongosh -u %s -p --authenticationDatabase admin", contName, dbuser)
-	openTerminal(cmd)
+	args := []string{"docker", "exec", "-it", strings.TrimPrefix(info.Name, "/")}
+	args = append(args, engine.CLI(DatabaseCredentialsFromLabels(info.Config.Labels))...)
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	openTerminal(strings.Join(args, " "))
+	return nil
 }
 
 func openTerminal(cmd string) {
@@ -125,34 +124,171 @@ func openTerminal(cmd string) {
 	}
 }
 
-func findFreePort(initialPort string) (string, error) {
-	port, err := strconv.Atoi(initialPort)
+// InputError reports an invalid value passed to database creation.
+type InputError struct {
+	Field  string
+	Reason string
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// ErrContainerExists is returned when the requested container name is
+// already taken.
+var ErrContainerExists = errors.New("container already exists")
+
+var (
+	containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	identifierPattern    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// validateDatabaseInput checks the values used to create a database. The
+// user and database names are restricted to plain identifiers, which every
+// engine accepts unquoted.
+func validateDatabaseInput(engine DatabaseEngineInfo, c DatabaseCredentials, containerName string) error {
+	if !containerNamePattern.MatchString(containerName) {
+		return &InputError{Field: "container name", Reason: "use letters, digits, '_', '.' or '-', starting with a letter or digit"}
+	}
+	if engine.Users {
+		if !identifierPattern.MatchString(c.User) || len(c.User) > 63 {
+			return &InputError{Field: "username", Reason: "use up to 63 letters, digits or '_', not starting with a digit"}
+		}
+		// The MySQL and MariaDB images create root themselves
+		if (engine.ID == "mysql" || engine.ID == "mariadb") && strings.EqualFold(c.User, "root") {
+			return &InputError{Field: "username", Reason: "root is reserved"}
+		}
+	}
+	if engine.Databases && (!identifierPattern.MatchString(c.Database) || len(c.Database) > 63) {
+		return &InputError{Field: "database name", Reason: "use up to 63 letters, digits or '_', not starting with a digit"}
+	}
+	if c.Password == "" {
+		return &InputError{Field: "password", Reason: "must not be empty"}
+	}
+	if strings.ContainsRune(c.Password, 0) {
+		return &InputError{Field: "password", Reason: "must not contain NUL characters"}
+	}
+	return nil
+}
+
+type databaseSpec struct {
+	image       string
+	port        int
+	dataDir     string
+	env         []string
+	cmd         []string
+	labels      map[string]string
+	healthcheck []string
+}
+
+// runDatabaseContainer pulls the image of spec if needed, then creates and
+// starts a database container publishing its port on a free host port. The
+// data directory is backed by a named volume of its own, which outlives the
+// container. It returns the ID of the new container.
+func runDatabaseContainer(ctx context.Context, containerName string, spec databaseSpec) (string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		return "", fmt.Errorf("invalid port number: %v", err)
+		return "", fmt.Errorf("failed to create Docker client: %v", err)
 	}
+	defer cli.Close()
 
-	for {
-		address := fmt.Sprintf(":%d", port)
-		listener, err := net.Listen("tcp", address)
+	if _, err := cli.ContainerInspect(ctx, containerName); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrContainerExists, containerName)
+	} else if !client.IsErrNotFound(err) {
+		return "", fmt.Errorf("failed to inspect container: %v", err)
+	}
+	volumeName := databaseVolumeName(containerName)
+	if _, err := cli.VolumeInspect(ctx, volumeName); err == nil {
+		return "", fmt.Errorf("%w: %s, remove it to reuse the name %s", ErrVolumeExists, volumeName, containerName)
+	} else if !client.IsErrNotFound(err) {
+		return "", fmt.Errorf("failed to inspect volume: %v", err)
+	}
+
+	if _, _, err := cli.ImageInspectWithRaw(ctx, spec.image); client.IsErrNotFound(err) {
+		response, err := cli.ImagePull(ctx, spec.image, image.PullOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to pull %s: %v", spec.image, err)
+		}
+		err = jsonmessage.DisplayJSONMessagesStream(response, io.Discard, 0, false, nil)
+		response.Close()
 		if err != nil {
-			if isAddrInUse(err) {
-				port++
-				continue
-			}
-			return "", fmt.Errorf("error checking port: %v", err)
+			return "", fmt.Errorf("failed to pull %s: %v", spec.image, err)
 		}
-		listener.Close()
-		return strconv.Itoa(port), nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to inspect image: %v", err)
 	}
-}
 
-func isAddrInUse(err error) bool {
-	if opErr, ok := err.(*net.OpError); ok {
-		if syscallErr, ok := opErr.Err.(*os.SyscallError); ok {
-			return syscallErr.Err == syscall.EADDRINUSE
+	reservation, err := reservePorts(ctx, cli, "")
+	if err != nil {
+		return "", err
+	}
+	defer reservation.Release()
+	allocated, err := reservation.Allocate(spec.port)
+	if err != nil {
+		return "", err
+	}
+	hostPort := strconv.Itoa(allocated)
+	port := nat.Port(fmt.Sprintf("%d/tcp", spec.port))
+
+	labels := map[string]string{
+		"createdBy": "Contanize",
+		"type":      "Database",
+	}
+	for key, value := range spec.labels {
+		labels[key] = value
+	}
+
+	_, err = cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name: volumeName,
+		Labels: map[string]string{
+			"createdBy":         "Contanize",
+			"db":                spec.labels["db"],
+			labelVolumeDatabase: containerName,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create volume: %v", err)
+	}
+
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:        spec.image,
+		Env:          spec.env,
+		Cmd:          spec.cmd,
+		Labels:       labels,
+		ExposedPorts: nat.PortSet{port: struct{}{}},
+		Healthcheck: &container.HealthConfig{
+			Test:     spec.healthcheck,
+			Interval: 10 * time.Second,
+			Timeout:  5 * time.Second,
+			Retries:  5,
+		},
+	}, &container.HostConfig{
+		PortBindings:  nat.PortMap{port: []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPort}}},
+		RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyUnlessStopped},
+		Mounts: []mount.Mount{{
+			Type:   mount.TypeVolume,
+			Source: volumeName,
+			Target: spec.dataDir,
+		}},
+	}, nil, nil, containerName)
+	if err != nil {
+		cli.VolumeRemove(ctx, volumeName, false)
+		return "", fmt.Errorf("failed to create container: %v", err)
+	}
+	reservation.Release()
+
+	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		// Leave nothing behind, so that the same name can be used again
+		if rmErr := cli.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true}); rmErr != nil {
+			log.Printf("Failed to remove container %s: %v", containerName, rmErr)
+		} else if rmErr := cli.VolumeRemove(context.Background(), volumeName, false); rmErr != nil {
+			log.Printf("Failed to remove volume %s: %v", volumeName, rmErr)
 		}
+		return "", fmt.Errorf("failed to start container: %v", err)
 	}
-	return false
+
+	log.Printf("%s container started successfully. Container ID: %s", spec.labels["db"], resp.ID)
+	return resp.ID, nil
 }
 
 func getDesktopEnvironment() string {
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// EventDatabaseProgress is emitted as a dump or restore moves through its
+// phases and while the archive is copied.
+const EventDatabaseProgress = "database:progress"
+
+// Dumps are written to and restored from this directory of the container.
+const dumpDir = "/tmp"
+
+type DatabaseProgress struct {
+	Container string `json:"container"`
+	// Operation is dump or restore.
+	Operation string `json:"operation"`
+	// Phase is one of dumping, copying, restoring and done.
+	Phase string `json:"phase"`
+	Bytes int64  `json:"bytes"`
+	Total int64  `json:"total"`
+}
+
+// DumpDatabase runs the dump tool of the engine inside a running database
+// container and copies the archive it writes to destPath on the host.
+func DumpDatabase(ctx context.Context, containerName, destPath string, emit EventFunc) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	info, engine, credentials, err := inspectDatabase(ctx, cli, containerName)
+	if err != nil {
+		return err
+	}
+	archive := path.Join(dumpDir, fmt.Sprintf("contanize-%s.dump", generateRandomString(8)))
+	cmd := engine.DumpCmd(credentials, archive)
+	if cmd == nil {
+		return fmt.Errorf("%s databases cannot be dumped", engine.Info().Name)
+	}
+	report := progressReporter(containerName, "dump", emit)
+
+	report("dumping", 0, 0)
+	defer execInContainer(context.Background(), cli, info.ID, []string{"rm", "-f", archive})
+	if err := execInContainer(ctx, cli, info.ID, cmd); err != nil {
+		return fmt.Errorf("failed to dump %s: %v", containerName, err)
+	}
+
+	content, _, err := cli.CopyFromContainer(ctx, info.ID, archive)
+	if err != nil {
+		return fmt.Errorf("failed to copy dump: %v", err)
+	}
+	defer content.Close()
+	tr := tar.NewReader(content)
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to copy dump: %v", err)
+	}
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", destPath, err)
+	}
+	report("copying", 0, header.Size)
+	_, err = io.Copy(out, &progressReader{r: tr, total: header.Size, report: func(n int64) {
+		report("copying", n, header.Size)
+	}})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("failed to copy dump: %v", err)
+	}
+
+	report("done", header.Size, header.Size)
+	return nil
+}
+
+// RestoreDatabase copies an archive written by DumpDatabase from srcPath
+// into a running database container and loads it with the restore tool of
+// the engine, replacing the objects it contains.
+func RestoreDatabase(ctx context.Context, containerName, srcPath string, emit EventFunc) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	info, engine, credentials, err := inspectDatabase(ctx, cli, containerName)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("contanize-%s.dump", generateRandomString(8))
+	archive := path.Join(dumpDir, name)
+	cmd := engine.RestoreCmd(credentials, archive)
+	if cmd == nil {
+		return fmt.Errorf("%s databases cannot be restored", engine.Info().Name)
+	}
+	report := progressReporter(containerName, "restore", emit)
+
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", srcPath, err)
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", srcPath, err)
+	}
+	total := fi.Size()
+
+	// The archive is streamed into the container as a single file tarball
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    total,
+			ModTime: time.Now(),
+		})
+		if err == nil {
+			_, err = io.Copy(tw, &progressReader{r: in, total: total, report: func(n int64) {
+				report("copying", n, total)
+			}})
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	report("copying", 0, total)
+	defer execInContainer(context.Background(), cli, info.ID, []string{"rm", "-f", archive})
+	err = cli.CopyToContainer(ctx, info.ID, dumpDir, pr, types.CopyToContainerOptions{})
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("failed to copy dump: %v", err)
+	}
+
+	report("restoring", total, total)
+	if err := execInContainer(ctx, cli, info.ID, cmd); err != nil {
+		return fmt.Errorf("failed to restore %s: %v", containerName, err)
+	}
+
+	report("done", total, total)
+	return nil
+}
+
+// inspectDatabase looks up a running database container along with its
+// engine and the credentials it was created with.
+func inspectDatabase(ctx context.Context, cli *client.Client, containerName string) (types.ContainerJSON, DatabaseEngine, DatabaseCredentials, error) {
+	info, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return info, nil, DatabaseCredentials{}, fmt.Errorf("failed to inspect container: %v", err)
+	}
+	if info.Config.Labels["type"] != "Database" {
+		return info, nil, DatabaseCredentials{}, fmt.Errorf("%s is not a database", containerName)
+	}
+	if info.State == nil || !info.State.Running {
+		return info, nil, DatabaseCredentials{}, fmt.Errorf("%s is not running", containerName)
+	}
+	engine, err := FindDatabaseEngine(info.Config.Labels["db"])
+	if err != nil {
+		return info, nil, DatabaseCredentials{}, err
+	}
+	return info, engine, DatabaseCredentialsFromLabels(info.Config.Labels), nil
+}
+
+// execInContainer runs cmd inside a container and waits for it to exit.
+// A non-zero exit status is returned as an error carrying its error output.
+func execInContainer(ctx context.Context, cli *client.Client, containerID string, cmd []string) error {
+	created, err := cli.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create exec: %v", err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{})
+	if err != nil {
+		return fmt.Errorf("failed to attach to exec: %v", err)
+	}
+	defer resp.Close()
+
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(io.Discard, &stderr, resp.Reader); err != nil {
+		return fmt.Errorf("failed to read exec output: %v", err)
+	}
+	result, err := cli.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec: %v", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s exited with status %d: %s", cmd[0], result.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func progressReporter(containerName, operation string, emit EventFunc) func(phase string, bytes, total int64) {
+	return func(phase string, bytes, total int64) {
+		if emit == nil {
+			return
+		}
+		emit(EventDatabaseProgress, DatabaseProgress{
+			Container: containerName,
+			Operation: operation,
+			Phase:     phase,
+			Bytes:     bytes,
+			Total:     total,
+		})
+	}
+}
+
+// progressReader reports the number of bytes read so far at most a few
+// times a second, and once more when the last byte is read.
+type progressReader struct {
+	r      io.Reader
+	n      int64
+	total  int64
+	last   time.Time
+	report func(n int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if now := time.Now(); n > 0 && (now.Sub(p.last) >= 250*time.Millisecond || p.n == p.total) {
+		p.last = now
+		p.report(p.n)
+	}
+	return n, err
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// DatabaseCredentials are the values a database container is created with.
+// Engines that have no users or databases ignore the matching field.
+type DatabaseCredentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+// DatabaseEngineInfo describes an engine to the create form.
+type DatabaseEngineInfo struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Group string `json:"group"`
+	Port  int    `json:"port"`
+	// Users and Databases tell whether the engine takes a username and a
+	// database name besides the password.
+	Users     bool `json:"users"`
+	Databases bool `json:"databases"`
+}
+
+// DatabaseEngine knows how to run one kind of database in a container. The
+// ID is stored in the db label of the containers it creates.
+type DatabaseEngine interface {
+	Info() DatabaseEngineInfo
+	Image() string
+	// DataDir is where the image keeps its data, a named volume is mounted
+	// there.
+	DataDir() string
+	// Env maps the credentials onto the environment of the image.
+	Env(c DatabaseCredentials) []string
+	// Cmd overrides the command of the image, nil keeps it.
+	Cmd(c DatabaseCredentials) []string
+	// HealthCheck is the probe run inside the container.
+	HealthCheck(c DatabaseCredentials) []string
+	// CLI is the interactive client run inside the container.
+	CLI(c DatabaseCredentials) []string
+	ConnectionString(c DatabaseCredentials, host string, port int) string
+	// DumpCmd writes an archive of the database to path inside the
+	// container, RestoreCmd loads one back. Both are nil for engines
+	// without dumps.
+	DumpCmd(c DatabaseCredentials, path string) []string
+	RestoreCmd(c DatabaseCredentials, path string) []string
+}
+
+var databaseEngines = []DatabaseEngine{
+	postgresEngine{},
+	mysqlEngine{
+		info:      DatabaseEngineInfo{ID: "mysql", Name: "MySQL", Group: "SQL", Port: 3306, Users: true, Databases: true},
+		image:     "mysql:8",
+		dataDir:   "/var/lib/mysql",
+		envPrefix: "MYSQL_",
+		client:    "mysql",
+		dumper:    "mysqldump",
+		probe:     []string{"CMD-SHELL", `mysqladmin ping -h 127.0.0.1 -u "$MYSQL_USER" --password="$MYSQL_PASSWORD" --silent`},
+	},
+	mysqlEngine{
+		info:      DatabaseEngineInfo{ID: "mariadb", Name: "MariaDB", Group: "SQL", Port: 3306, Users: true, Databases: true},
+		image:     "mariadb:11",
+		dataDir:   "/var/lib/mysql",
+		envPrefix: "MARIADB_",
+		client:    "mariadb",
+		dumper:    "mariadb-dump",
+		probe:     []string{"CMD", "healthcheck.sh", "--connect", "--innodb_initialized"},
+	},
+	mongoEngine{},
+	redisEngine{},
+}
+
+// ListDatabaseEngines returns the supported database engines.
+func ListDatabaseEngines() []DatabaseEngineInfo {
+	engines := make([]DatabaseEngineInfo, 0, len(databaseEngines))
+	for _, engine := range databaseEngines {
+		engines = append(engines, engine.Info())
+	}
+	return engines
+}
+
+// FindDatabaseEngine returns the engine with the given ID.
+func FindDatabaseEngine(id string) (DatabaseEngine, error) {
+	for _, engine := range databaseEngines {
+		if engine.Info().ID == id {
+			return engine, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported database type: %s", id)
+}
+
+func hostPort(host string, port int) string {
+	return host + ":" + strconv.Itoa(port)
+}
+
+type postgresEngine struct{}
+
+func (postgresEngine) Info() DatabaseEngineInfo {
+	return DatabaseEngineInfo{ID: "postgres", Name: "PostgreSQL", Group: "SQL", Port: 5432, Users: true, Databases: true}
+}
+
+// The major version is pinned, as the data directory of one major version
+// cannot be opened by the next without an upgrade.
+func (postgresEngine) Image() string { return "postgres:18-alpine" }
+
+// Releases from 18 on keep their data in a versioned directory below this
+// one, so the volume also survives a later pg_upgrade to a newer major.
+func (postgresEngine) DataDir() string { return "/var/lib/postgresql" }
+
+func (postgresEngine) Env(c DatabaseCredentials) []string {
+	return []string{
+		"POSTGRES_USER=" + c.User,
+		"POSTGRES_PASSWORD=" + c.Password,
+		"POSTGRES_DB=" + c.Database,
+	}
+}
+
+func (postgresEngine) Cmd(c DatabaseCredentials) []string { return nil }
+
+func (postgresEngine) HealthCheck(c DatabaseCredentials) []string {
+	return []string{"CMD", "pg_isready", "-U", c.User, "-d", c.Database}
+}
+
+func (postgresEngine) CLI(c DatabaseCredentials) []string {
+	cli := []string{"psql", "-U", c.User}
+	if c.Database != "" {
+		cli = append(cli, "-d", c.Database)
+	}
+	return cli
+}
+
+func (postgresEngine) ConnectionString(c DatabaseCredentials, host string, port int) string {
+	u := url.URL{Scheme: "postgresql", User: url.UserPassword(c.User, c.Password), Host: hostPort(host, port), Path: "/" + c.Database}
+	return u.String()
+}
+
+// The database defaults to the name of the user, as it does for psql.
+func (postgresEngine) database(c DatabaseCredentials) string {
+	if c.Database == "" {
+		return c.User
+	}
+	return c.Database
+}
+
+func (e postgresEngine) DumpCmd(c DatabaseCredentials, path string) []string {
+	return []string{"pg_dump", "-U", c.User, "-d", e.database(c), "--format=custom", "--file=" + path}
+}
+
+func (e postgresEngine) RestoreCmd(c DatabaseCredentials, path string) []string {
+	return []string{"pg_restore", "-U", c.User, "-d", e.database(c), "--clean", "--if-exists", "--no-owner", path}
+}
+
+// mysqlEngine covers MySQL and MariaDB, whose images differ only in the
+// prefix of their variables and the name of the client.
+type mysqlEngine struct {
+	info      DatabaseEngineInfo
+	image     string
+	dataDir   string
+	envPrefix string
+	client    string
+	dumper    string
+	probe     []string
+}
+
+func (e mysqlEngine) Info() DatabaseEngineInfo { return e.info }
+
+func (e mysqlEngine) Image() string { return e.image }
+
+func (e mysqlEngine) DataDir() string { return e.dataDir }
+
+// The user is granted all privileges on its database only, root gets a
+// random password of its own that the app never uses.
+func (e mysqlEngine) Env(c DatabaseCredentials) []string {
+	return []string{
+		e.envPrefix + "USER=" + c.User,
+		e.envPrefix + "PASSWORD=" + c.Password,
+		e.envPrefix + "DATABASE=" + c.Database,
+		e.envPrefix + "RANDOM_ROOT_PASSWORD=yes",
+	}
+}
+
+func (e mysqlEngine) Cmd(c DatabaseCredentials) []string { return nil }
+
+func (e mysqlEngine) HealthCheck(c DatabaseCredentials) []string { return e.probe }
+
+func (e mysqlEngine) CLI(c DatabaseCredentials) []string {
+	cli := []string{e.client, "-u", c.User}
+	if c.Password != "" {
+		cli = append(cli, "--password="+c.Password)
+	} else {
+		cli = append(cli, "-p")
+	}
+	if c.Database != "" {
+		cli = append(cli, c.Database)
+	}
+	return cli
+}
+
+func (e mysqlEngine) ConnectionString(c DatabaseCredentials, host string, port int) string {
+	u := url.URL{Scheme: "mysql", User: url.UserPassword(c.User, c.Password), Host: hostPort(host, port), Path: "/" + c.Database}
+	return u.String()
+}
+
+func (e mysqlEngine) DumpCmd(c DatabaseCredentials, path string) []string {
+	return []string{e.dumper, "-u", c.User, "--password=" + c.Password, "--single-transaction", "--routines", "--no-tablespaces", "--result-file=" + path, c.Database}
+}
+
+// The client only reads SQL from its input, which exec cannot redirect
+func (e mysqlEngine) RestoreCmd(c DatabaseCredentials, path string) []string {
+	return []string{"sh", "-c", `exec "$0" -u "$1" --password="$2" "$3" < "$4"`, e.client, c.User, c.Password, c.Database, path}
+}
+
+type mongoEngine struct{}
+
+func (mongoEngine) Info() DatabaseEngineInfo {
+	return DatabaseEngineInfo{ID: "mongo", Name: "MongoDB", Group: "NoSQL", Port: 27017, Users: true, Databases: true}
+}
+
+func (mongoEngine) Image() string { return "mongo:latest" }
+
+func (mongoEngine) DataDir() string { return "/data/db" }
+
+func (mongoEngine) Env(c DatabaseCredentials) []string {
+	return []string{
+		"MONGO_INITDB_ROOT_USERNAME=" + c.User,
+		"MONGO_INITDB_ROOT_PASSWORD=" + c.Password,
+		"MONGO_INITDB_DATABASE=" + c.Database,
+	}
+}
+
+func (mongoEngine) Cmd(c DatabaseCredentials) []string { return nil }
+
+func (mongoEngine) HealthCheck(c DatabaseCredentials) []string {
+	return []string{"CMD", "mongosh", "--quiet", "--eval", "db.runCommand({ ping: 1 })"}
+}
+
+func (mongoEngine) CLI(c DatabaseCredentials) []string {
+	cli := []string{"mongosh", "-u", c.User, "-p"}
+	if c.Password != "" {
+		cli = append(cli, c.Password)
+	}
+	cli = append(cli, "--authenticationDatabase", "admin")
+	if c.Database != "" {
+		cli = append(cli, c.Database)
+	}
+	return cli
+}
+
+func (mongoEngine) ConnectionString(c DatabaseCredentials, host string, port int) string {
+	u := url.URL{Scheme: "mongodb", User: url.UserPassword(c.User, c.Password), Host: hostPort(host, port), Path: "/" + c.Database, RawQuery: "authSource=admin"}
+	return u.String()
+}
+
+func (mongoEngine) auth(c DatabaseCredentials) []string {
+	return []string{"--username=" + c.User, "--password=" + c.Password, "--authenticationDatabase=admin"}
+}
+
+func (e mongoEngine) DumpCmd(c DatabaseCredentials, path string) []string {
+	cmd := append([]string{"mongodump"}, e.auth(c)...)
+	if c.Database != "" {
+		cmd = append(cmd, "--db="+c.Database)
+	}
+	return append(cmd, "--archive="+path, "--gzip")
+}
+
+func (e mongoEngine) RestoreCmd(c DatabaseCredentials, path string) []string {
+	cmd := append([]string{"mongorestore"}, e.auth(c)...)
+	return append(cmd, "--drop", "--archive="+path, "--gzip")
+}
+
+// redisEngine runs Redis with a password and the default user.
+type redisEngine struct{}
+
+func (redisEngine) Info() DatabaseEngineInfo {
+	return DatabaseEngineInfo{ID: "redis", Name: "Redis", Group: "Key-value", Port: 6379}
+}
+
+func (redisEngine) Image() string { return "redis:7-alpine" }
+
+func (redisEngine) DataDir() string { return "/data" }
+
+// The image has no variable for the password, it is passed on the command
+// line and kept in the environment for the probe and the client.
+func (redisEngine) Env(c DatabaseCredentials) []string {
+	return []string{"REDISCLI_AUTH=" + c.Password}
+}
+
+func (redisEngine) Cmd(c DatabaseCredentials) []string {
+	return []string{"redis-server", "--appendonly", "yes", "--requirepass", c.Password}
+}
+
+func (redisEngine) HealthCheck(c DatabaseCredentials) []string {
+	return []string{"CMD", "redis-cli", "ping"}
+}
+
+func (redisEngine) CLI(c DatabaseCredentials) []string {
+	return []string{"redis-cli"}
+}
+
+func (redisEngine) ConnectionString(c DatabaseCredentials, host string, port int) string {
+	u := url.URL{Scheme: "redis", User: url.UserPassword("", c.Password), Host: hostPort(host, port)}
+	return u.String()
+}
+
+func (redisEngine) DumpCmd(c DatabaseCredentials, path string) []string { return nil }
+
+func (redisEngine) RestoreCmd(c DatabaseCredentials, path string) []string { return nil }
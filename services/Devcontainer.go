@@ -0,0 +1,465 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// devcontainerDockerfile adds code-server and the Contanize entrypoint on
+// top of the image described by a devcontainer.json.
+const devcontainerDockerfile = "dockerfile.devcontainer"
+
+// workspaceFolder is where the host folder is mounted in every workspace.
+const workspaceFolder = "/home/coder"
+
+type DevcontainerBuild struct {
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Args       map[string]string `json:"args"`
+	Target     string            `json:"target"`
+}
+
+// Devcontainer is the subset of devcontainer.json Contanize understands.
+type Devcontainer struct {
+	Name              string             `json:"name"`
+	Image             string             `json:"image"`
+	Build             *DevcontainerBuild `json:"build"`
+	DockerFile        string             `json:"dockerFile"`
+	Context           string             `json:"context"`
+	ForwardPorts      []interface{}      `json:"forwardPorts"`
+	ContainerEnv      map[string]string  `json:"containerEnv"`
+	Mounts            []interface{}      `json:"mounts"`
+	PostCreateCommand interface{}        `json:"postCreateCommand"`
+	Customizations    struct {
+		VSCode struct {
+			Extensions []string `json:"extensions"`
+		} `json:"vscode"`
+	} `json:"customizations"`
+
+	// Path is the devcontainer.json file, Folder the workspace folder it
+	// was found in.
+	Path   string `json:"-"`
+	Folder string `json:"-"`
+}
+
+// DevcontainerInfo summarises a devcontainer.json for the frontend.
+type DevcontainerInfo struct {
+	Path              string            `json:"path"`
+	Name              string            `json:"name"`
+	Image             string            `json:"image"`
+	Dockerfile        string            `json:"dockerfile"`
+	Ports             []string          `json:"ports"`
+	Env               map[string]string `json:"env"`
+	PostCreateCommand string            `json:"postCreateCommand"`
+	Extensions        []string          `json:"extensions"`
+}
+
+// FindDevcontainer returns the devcontainer.json of folder, if it has one.
+func FindDevcontainer(folder string) (string, bool) {
+	candidates := []string{
+		filepath.Join(folder, ".devcontainer", "devcontainer.json"),
+		filepath.Join(folder, ".devcontainer.json"),
+	}
+	for _, candidate := range candidates {
+		if fi, err := os.Stat(candidate); err == nil && fi.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// LoadDevcontainer parses the devcontainer.json at path for the workspace
+// in folder.
+func LoadDevcontainer(path, folder string) (*Devcontainer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading devcontainer.json: %v", err)
+	}
+
+	var dev Devcontainer
+	if err := json.Unmarshal(stripJSONC(data), &dev); err != nil {
+		return nil, fmt.Errorf("error parsing devcontainer.json: %v", err)
+	}
+	dev.Path = path
+	dev.Folder = folder
+
+	if dev.Build == nil && dev.DockerFile != "" {
+		dev.Build = &DevcontainerBuild{Dockerfile: dev.DockerFile, Context: dev.Context}
+	}
+	if dev.Image == "" && (dev.Build == nil || dev.Build.Dockerfile == "") {
+		return nil, fmt.Errorf("devcontainer.json must declare an image or a Dockerfile")
+	}
+	return &dev, nil
+}
+
+func (d *Devcontainer) Info() DevcontainerInfo {
+	info := DevcontainerInfo{
+		Path:              d.Path,
+		Name:              d.Name,
+		Image:             d.Image,
+		Ports:             d.Ports(),
+		Env:               d.Env(),
+		PostCreateCommand: d.PostCreate(),
+		Extensions:        d.Customizations.VSCode.Extensions,
+	}
+	if d.Build != nil {
+		info.Dockerfile = d.Build.Dockerfile
+	}
+	return info
+}
+
+// Ports returns the forwarded container ports. Ports of other services,
+// written as "service:port", are skipped.
+func (d *Devcontainer) Ports() []string {
+	var ports []string
+	for _, p := range d.ForwardPorts {
+		switch v := p.(type) {
+		case float64:
+			ports = append(ports, strconv.Itoa(int(v)))
+		case string:
+			if _, err := strconv.Atoi(v); err == nil {
+				ports = append(ports, v)
+			}
+		}
+	}
+	return ports
+}
+
+// Env returns containerEnv with its variables substituted.
+func (d *Devcontainer) Env() map[string]string {
+	env := make(map[string]string, len(d.ContainerEnv))
+	for k, v := range d.ContainerEnv {
+		env[k] = d.substitute(v)
+	}
+	return env
+}
+
+// EnvList returns containerEnv in KEY=value form, sorted by key.
+func (d *Devcontainer) EnvList() []string {
+	env := d.Env()
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// MountList converts the devcontainer mounts, given either as
+// "source=...,target=...,type=..." strings or as objects.
+func (d *Devcontainer) MountList() ([]mount.Mount, error) {
+	var mounts []mount.Mount
+	for _, m := range d.Mounts {
+		fields := make(map[string]string)
+		switch v := m.(type) {
+		case string:
+			for _, part := range strings.Split(v, ",") {
+				key, value, _ := strings.Cut(part, "=")
+				fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		case map[string]interface{}:
+			for key, value := range v {
+				fields[key] = fmt.Sprint(value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported mount: %v", m)
+		}
+
+		target := fields["target"]
+		if target == "" {
+			target = fields["destination"]
+		}
+		if target == "" {
+			target = fields["dst"]
+		}
+		source := fields["source"]
+		if source == "" {
+			source = fields["src"]
+		}
+		mountType := mount.Type(fields["type"])
+		if mountType == "" {
+			mountType = mount.TypeVolume
+		}
+		if target == "" {
+			return nil, fmt.Errorf("mount without target: %v", m)
+		}
+
+		// readonly is either a bare flag or a boolean
+		readOnly, ok := fields["readonly"]
+		mounts = append(mounts, mount.Mount{
+			Type:     mountType,
+			Source:   d.substitute(source),
+			Target:   d.substitute(target),
+			ReadOnly: ok && (readOnly == "" || readOnly == "true"),
+		})
+	}
+	return mounts, nil
+}
+
+// PostCreate returns postCreateCommand as a single shell command.
+func (d *Devcontainer) PostCreate() string {
+	switch v := d.PostCreateCommand.(type) {
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, arg := range v {
+			parts = append(parts, shellQuote(fmt.Sprint(arg)))
+		}
+		return strings.Join(parts, " ")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		commands := make([]string, 0, len(v))
+		for _, k := range keys {
+			sub := &Devcontainer{PostCreateCommand: v[k]}
+			commands = append(commands, sub.PostCreate())
+		}
+		return strings.Join(commands, " && ")
+	}
+	return ""
+}
+
+// substitute replaces the devcontainer variables Contanize can resolve.
+func (d *Devcontainer) substitute(s string) string {
+	s = strings.ReplaceAll(s, "${localWorkspaceFolder}", d.Folder)
+	s = strings.ReplaceAll(s, "${localWorkspaceFolderBasename}", filepath.Base(d.Folder))
+	s = strings.ReplaceAll(s, "${containerWorkspaceFolder}", workspaceFolder)
+	for {
+		start := strings.Index(s, "${localEnv:")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			break
+		}
+		name, fallback, _ := strings.Cut(s[start+len("${localEnv:"):start+end], ":")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			value = fallback
+		}
+		s = s[:start] + value + s[start+end+1:]
+	}
+	return s
+}
+
+// ensureDevcontainerBase pulls or builds the image a devcontainer.json
+// describes and returns its reference.
+func (dc *DockerCreate) ensureDevcontainerBase(ctx context.Context, opts CreateOptions, buildID string, transcript *strings.Builder) (string, error) {
+	dev := opts.Devcontainer
+	if dev.Build == nil || dev.Build.Dockerfile == "" {
+		response, err := dc.cli.ImagePull(ctx, dev.Image, image.PullOptions{Platform: opts.Platform})
+		if err != nil {
+			return "", fmt.Errorf("failed to pull %s: %v", dev.Image, err)
+		}
+		defer response.Close()
+		output, err := dc.readBuildOutput(buildID, response)
+		transcript.WriteString(output)
+		if err != nil {
+			return "", err
+		}
+		return dev.Image, nil
+	}
+
+	// Paths in devcontainer.json are relative to the file itself
+	devDir := filepath.Dir(dev.Path)
+	contextDir := filepath.Join(devDir, dev.Build.Context)
+	dockerfile, err := filepath.Rel(contextDir, filepath.Join(devDir, dev.Build.Dockerfile))
+	if err != nil {
+		return "", fmt.Errorf("invalid devcontainer Dockerfile path: %v", err)
+	}
+
+	buf, err := tarDirectory(contextDir)
+	if err != nil {
+		return "", err
+	}
+
+	buildArgs := make(map[string]*string, len(dev.Build.Args))
+	for k, v := range dev.Build.Args {
+		value := dev.substitute(v)
+		buildArgs[k] = &value
+	}
+
+	tag := devcontainerImage(opts.Name)
+	response, err := dc.cli.ImageBuild(ctx, buf, types.ImageBuildOptions{
+		Dockerfile: filepath.ToSlash(dockerfile),
+		Tags:       []string{tag},
+		BuildArgs:  buildArgs,
+		Target:     dev.Build.Target,
+		Labels: map[string]string{
+			"createdBy": "Contanize",
+			labelKind:   kindDevcontainer,
+		},
+		Remove:     true,
+		NoCache:    opts.Refresh,
+		PullParent: opts.Refresh,
+		Platform:   opts.Platform,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to build devcontainer image: %v", err)
+	}
+	defer response.Body.Close()
+
+	output, err := dc.readBuildOutput(buildID, response.Body)
+	transcript.WriteString(output)
+	if err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// devcontainerImage returns the tag of the image built from the
+// devcontainer.json of the workspace called name.
+func devcontainerImage(name string) string {
+	return fmt.Sprintf("contanize-devcontainer:%s", name)
+}
+
+// tarDirectory packs a host directory into an in-memory build context.
+func tarDirectory(dir string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := writeDirectoryTar(&buf, dir); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// writeDirectoryTar writes the regular files and directories under dir to
+// w as a tarball.
+func writeDirectoryTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(dir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(fi, fi.Name())
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(tw, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error creating tarball: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error closing tar writer: %v", err)
+	}
+	return nil
+}
+
+// stripJSONC removes the comments and trailing commas devcontainer.json
+// allows, leaving plain JSON.
+func stripJSONC(data []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out.WriteByte(data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out.WriteByte('\n')
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			// Drop the comma if only whitespace and comments separate it
+			// from a closing bracket
+			j := skipJSONCSpace(data, i+1)
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+// skipJSONCSpace returns the index of the first byte from i on that is
+// neither whitespace nor part of a comment.
+func skipJSONCSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(data[i])):
+			i++
+		case i+1 < len(data) && data[i] == '/' && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case i+1 < len(data) && data[i] == '/' && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i += 2
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
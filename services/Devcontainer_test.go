@@ -0,0 +1,41 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"plain", `{"name": "app"}`, `{"name": "app"}`},
+		{"line comment", "{\n// the name\n\"name\": \"app\" // trailing\n}", `{"name": "app"}`},
+		{"line comment at end", "{\"name\": \"app\"}\n// done", `{"name": "app"}`},
+		{"block comment", `{/* the name */ "name": /* inline */ "app"}`, `{"name": "app"}`},
+		{"multiline block comment", "{\n/*\n * ports\n */\n\"forwardPorts\": [3000]\n}", `{"forwardPorts": [3000]}`},
+		{"trailing comma in object", "{\"name\": \"app\",\n}", `{"name": "app"}`},
+		{"trailing comma in array", `{"forwardPorts": [3000, 8080, ]}`, `{"forwardPorts": [3000, 8080]}`},
+		{"trailing comma before comment", "{\"name\": \"app\", // last\n}", `{"name": "app"}`},
+		{"trailing comma before block comment", `{"forwardPorts": [3000, /* 8080 */]}`, `{"forwardPorts": [3000]}`},
+		{"slashes in string", `{"image": "http://example.com//x", "glob": "/*"}`, `{"image": "http://example.com//x", "glob": "/*"}`},
+		{"escaped quote in string", `{"cmd": "echo \"// not a comment\""}`, `{"cmd": "echo \"// not a comment\""}`},
+		{"comma in string", `{"list": "a, ]", "b": [1]}`, `{"list": "a, ]", "b": [1]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, want interface{}
+			stripped := stripJSONC([]byte(tt.input))
+			if err := json.Unmarshal(stripped, &got); err != nil {
+				t.Fatalf("stripJSONC(%q) = %q, not valid JSON: %v", tt.input, stripped, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("stripJSONC(%q) = %q, want %s", tt.input, stripped, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Events emitted to the frontend when the Docker daemon reports a change
+// on a Contanize managed container or image.
+const (
+	EventContainerCreated   = "container:created"
+	EventContainerStarted   = "container:started"
+	EventContainerDied      = "container:died"
+	EventContainerDestroyed = "container:destroyed"
+	EventContainerHealth    = "container:health"
+	EventImageBuilt         = "image:built"
+	EventImageRemoved       = "image:removed"
+)
+
+const (
+	eventsMinBackoff = 1 * time.Second
+	eventsMaxBackoff = 30 * time.Second
+)
+
+// EventFunc receives an event name together with its payload.
+type EventFunc func(name string, payload interface{})
+
+type DockerEvent struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Image  string `json:"image"`
+	Action string `json:"action"`
+	Status string `json:"status"`
+	Time   int64  `json:"time"`
+}
+
+type DockerEvents struct {
+	emit EventFunc
+}
+
+func NewDockerEvents(emit EventFunc) *DockerEvents {
+	return &DockerEvents{emit: emit}
+}
+
+// Watch subscribes to the Docker events stream until ctx is cancelled,
+// reconnecting with exponential backoff whenever the stream breaks.
+func (de *DockerEvents) Watch(ctx context.Context) {
+	backoff := eventsMinBackoff
+	since := time.Now()
+	for {
+		connected := time.Now()
+		last, err := de.watchOnce(ctx, since)
+		if !last.IsZero() {
+			since = last
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A connection that stayed up for a while was healthy, start over
+		if time.Since(connected) > eventsMaxBackoff {
+			backoff = eventsMinBackoff
+		}
+		log.Printf("Docker event stream interrupted: %v. Reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > eventsMaxBackoff {
+			backoff = eventsMaxBackoff
+		}
+	}
+}
+
+func (de *DockerEvents) watchOnce(ctx context.Context, since time.Time) (time.Time, error) {
+	var last time.Time
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return last, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	filter := filters.NewArgs(
+		filters.Arg("label", "createdBy=Contanize"),
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("type", string(events.ImageEventType)),
+	)
+	msgs, errs := cli.Events(ctx, types.EventsOptions{
+		Since:   eventsSince(since),
+		Filters: filter,
+	})
+
+	for {
+		select {
+		case msg := <-msgs:
+			// The daemon replays events from since on, including the last
+			// one already delivered before the stream broke
+			if msg.TimeNano <= since.UnixNano() {
+				continue
+			}
+			last = time.Unix(0, msg.TimeNano)
+			de.dispatch(msg)
+		case err := <-errs:
+			return last, err
+		}
+	}
+}
+
+// eventsSince formats t as the seconds.nanoseconds timestamp the events
+// API accepts, so that the stream resumes without losing precision.
+func eventsSince(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+func (de *DockerEvents) dispatch(msg events.Message) {
+	name, status := eventName(msg)
+	if name == "" {
+		return
+	}
+
+	de.emit(name, DockerEvent{
+		ID:     msg.Actor.ID,
+		Name:   msg.Actor.Attributes["name"],
+		Image:  msg.Actor.Attributes["image"],
+		Action: string(msg.Action),
+		Status: status,
+		Time:   msg.Time,
+	})
+}
+
+// eventName maps a Docker event to the frontend event it should be
+// published as. Events that are not of interest map to an empty name.
+func eventName(msg events.Message) (string, string) {
+	switch msg.Type {
+	case events.ContainerEventType:
+		switch {
+		case msg.Action == events.ActionCreate:
+			return EventContainerCreated, ""
+		case msg.Action == events.ActionStart:
+			return EventContainerStarted, ""
+		case msg.Action == events.ActionDie:
+			return EventContainerDied, msg.Actor.Attributes["exitCode"]
+		case msg.Action == events.ActionDestroy:
+			return EventContainerDestroyed, ""
+		case strings.HasPrefix(string(msg.Action), string(events.ActionHealthStatus)):
+			status := strings.TrimPrefix(string(msg.Action), string(events.ActionHealthStatus))
+			return EventContainerHealth, strings.TrimSpace(strings.TrimPrefix(status, ":"))
+		}
+	case events.ImageEventType:
+		// Image deletion events carry no labels, so removals are reported
+		// through the untag event that precedes them.
+		switch msg.Action {
+		case events.ActionTag:
+			return EventImageBuilt, ""
+		case events.ActionUnTag:
+			return EventImageRemoved, ""
+		}
+	}
+	return "", ""
+}
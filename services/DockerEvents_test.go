@@ -0,0 +1,22 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventsSince(t *testing.T) {
+	tests := []struct {
+		time time.Time
+		want string
+	}{
+		{time.Unix(1700000000, 0), "1700000000.000000000"},
+		{time.Unix(1700000000, 5), "1700000000.000000005"},
+		{time.Unix(1700000000, 123456789), "1700000000.123456789"},
+	}
+	for _, tt := range tests {
+		if got := eventsSince(tt.time); got != tt.want {
+			t.Errorf("eventsSince(%v) = %q, want %q", tt.time, got, tt.want)
+		}
+	}
+}
@@ -1,14 +1,13 @@
 package services
 
 import (
-	"archive/tar"
-	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
-	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -17,14 +16,29 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/go-connections/nat"
 	"gopkg.in/yaml.v3"
 )
 
+// EventBuildProgress is emitted for every message of a running image build.
+const EventBuildProgress = "build:progress"
+
 type DockerCreate struct {
 	cli        *client.Client
 	configPath string
+	emit       EventFunc
+}
+
+type BuildProgress struct {
+	BuildID  string `json:"buildId"`
+	Stream   string `json:"stream,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Progress string `json:"progress,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
 func NewDockerCreate() (*DockerCreate, error) {
@@ -33,10 +47,9 @@ func NewDockerCreate() (*DockerCreate, error) {
 		return nil, fmt.Errorf("failed to create Docker client: %v", err)
 	}
 
-	// configDir, err := os.UserConfigDir()
-	configDir, err := os.Getwd()
+	configDir, err := ConfigDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user config directory: %v", err)
+		return nil, err
 	}
 
 	configPath := filepath.Join(configDir, "wails-docker-manager.yaml")
@@ -65,23 +78,6 @@ func (dc *DockerCreate) LoadContainerInfo() (*ContainerInfo, error) {
 	return &info, nil
 }
 
-func isPortInUse(port int) bool {
-	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
-		return true
-	}
-	ln.Close()
-	return false
-}
-
-func findAvailablePort(startPort int) int {
-	port := startPort
-	for isPortInUse(port) {
-		port++
-	}
-	return port
-}
-
 func generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
 	seededRand := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -92,97 +88,99 @@ func generateRandomString(length int) string {
 	return string(b)
 }
 
-func (dc *DockerCreate) CreateContainer(name, technology, volume, additionalPorts, templateName string) error {
-	ctx := context.Background()
+// CreateOptions describes a workspace to build and run.
+type CreateOptions struct {
+	Name string
+	// Runtime and RuntimeVersion must have been checked with ResolveRuntime.
+	Runtime        string
+	RuntimeVersion string
+	Volume         string
+	Ports          string
+	Template       string
+	// Scaffold is the template scaffold script run on first start.
+	Scaffold string
+	// Platform optionally selects the build platform, such as linux/arm64.
+	// The daemon platform is used when it is empty.
+	Platform string
+	// Devcontainer, when set, replaces the runtime base image with the
+	// image, ports, env and mounts of a devcontainer.json.
+	Devcontainer *Devcontainer
+	// Refresh rebuilds the base image without cache even if it exists,
+	// picking up new releases of the runtime and code-server.
+	Refresh bool
+	// BuildID identifies the build in progress events, the image name is
+	// used when it is empty.
+	BuildID string
+	// OnPhase is called whenever creation moves to a new phase.
+	OnPhase func(JobPhase)
+}
+
+func (opts CreateOptions) phase(phase JobPhase) {
+	if opts.OnPhase != nil {
+		opts.OnPhase(phase)
+	}
+}
+
+// CreateContainer builds the workspace image, then creates and starts its
+// container. It returns the ID of the new container. Cancelling ctx aborts
+// the build, or removes the container if it was already created.
+func (dc *DockerCreate) CreateContainer(ctx context.Context, opts CreateOptions) (string, error) {
+	name := opts.Name
+	volume := opts.Volume
+	additionalPorts := opts.Ports
+	templateName := opts.Template
 
 	// Check if a container with the same name already exists
 	containers, err := dc.cli.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
-		return fmt.Errorf("failed to list containers: %v", err)
+		return "", fmt.Errorf("failed to list containers: %v", err)
 	}
 	for _, container := range containers {
 		if container.Names[0] == "/"+name {
-			fmt.Printf("Container with name %s already exists. Skipping creation.\n", name)
-			return nil
+			return "", fmt.Errorf("container with name %s already exists", name)
 		}
 	}
 
 	// Generate image name
 	imageName := fmt.Sprintf("%s-%s", name, generateRandomString(8))
-
-	// Building Docker image
-	buildArgs := map[string]*string{
-		"ADDITIONAL_PACKAGES": &technology,
-		"ADDITIONAL_PORT":     &additionalPorts,
-		"TEMPLATE_NAME":       &templateName,
+	buildID := opts.BuildID
+	if buildID == "" {
+		buildID = imageName
 	}
-	dockerfilePath := "/usr/local/share/contanize/dockerfile"
-	fmt.Println("Dockerfile path:", dockerfilePath)
 
-	var buf bytes.Buffer
-	tw := tar.NewWriter(&buf)
-
-	buildContext := "/usr/local/share/contanize/"
-	err = filepath.Walk(buildContext, func(file string, fi os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		header, err := tar.FileInfoHeader(fi, fi.Name())
-		if err != nil {
-			return err
-		}
-		relPath, err := filepath.Rel(buildContext, file)
-		if err != nil {
-			return err
-		}
-		header.Name = relPath
-		if err := tw.WriteHeader(header); err != nil {
-			return err
-		}
-		if fi.Mode().IsRegular() {
-			f, err := os.Open(file)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			if _, err := io.Copy(tw, f); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	arch, err := dc.targetArch(ctx, opts.Platform)
 	if err != nil {
-		return fmt.Errorf("error creating tarball: %v", err)
-	}
-	if err := tw.Close(); err != nil {
-		return fmt.Errorf("error closing tar writer: %v", err)
+		return "", err
 	}
 
-	buildOptions := types.ImageBuildOptions{
-		Dockerfile: "dockerfile",
-		Tags:       []string{imageName},
-		BuildArgs:  buildArgs,
-		NoCache:    true,
-		Remove:     true,
+	opts.phase(PhaseBuilding)
+	var transcript strings.Builder
+	if err := dc.buildWorkspaceImage(ctx, opts, imageName, arch, buildID, &transcript); err != nil {
+		return "", err
 	}
-	response, err := dc.cli.ImageBuild(ctx, &buf, buildOptions)
-	if err != nil {
-		return fmt.Errorf("failed to build Docker image: %v", err)
-	}
-	defer response.Body.Close()
+	// The image is removed again unless a container ends up running it
+	imageInUse := false
+	defer func() {
+		if !imageInUse {
+			dc.discardImage(imageName)
+		}
+	}()
 
-	buildOutput := new(bytes.Buffer)
-	if _, err := io.Copy(buildOutput, response.Body); err != nil {
-		return fmt.Errorf("error reading build output: %v", err)
+	reservation, err := reservePorts(ctx, dc.cli, name)
+	if err != nil {
+		return "", err
 	}
-	fmt.Println("Build output:", buildOutput.String())
+	defer reservation.Release()
 
 	portMappings := make(map[string]string)
 	portBindings := nat.PortMap{}
 	exposedPorts := nat.PortSet{}
 
 	// Handling main port (8080)
-	mainExternalPort := findAvailablePort(8080)
+	mainExternalPort, err := reservation.Allocate(8080)
+	if err != nil {
+		return "", err
+	}
 	portMappings["8080"] = strconv.Itoa(mainExternalPort)
 	portBindings[nat.Port("8080")] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: strconv.Itoa(mainExternalPort)}}
 	exposedPorts[nat.Port("8080")] = struct{}{}
@@ -192,33 +190,62 @@ func (dc *DockerCreate) CreateContainer(name, technology, volume, additionalPort
 	for _, internalPort := range additionalPortsList {
 		if internalPort != "" {
 			internalPort = strings.TrimSpace(internalPort)
-			if internalPort == "" {
+			if internalPort == "" || internalPort == "8080" {
 				continue
 			}
-			portInt, _ := strconv.Atoi(internalPort)
-			externalPort := findAvailablePort(portInt)
+			portInt, err := ParsePort(internalPort)
+			if err != nil {
+				return "", err
+			}
+			externalPort, err := reservation.Allocate(portInt)
+			if err != nil {
+				return "", err
+			}
 			portMappings[internalPort] = strconv.Itoa(externalPort)
 			portBindings[nat.Port(internalPort)] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: strconv.Itoa(externalPort)}}
 			exposedPorts[nat.Port(internalPort)] = struct{}{}
 		}
 	}
 
+	var env []string
+	var mounts []mount.Mount
+	if opts.Scaffold != "" {
+		env = append(env, "TEMPLATE_SCAFFOLD="+opts.Scaffold)
+	}
+	if dev := opts.Devcontainer; dev != nil {
+		env = append(env, dev.EnvList()...)
+		if command := dev.PostCreate(); command != "" {
+			env = append(env, "POST_CREATE_COMMAND="+command)
+		}
+		if mounts, err = dev.MountList(); err != nil {
+			return "", err
+		}
+	}
+
+	opts.phase(PhaseCreating)
 	resp, err := dc.cli.ContainerCreate(ctx, &container.Config{
 		Image:        imageName,
 		ExposedPorts: exposedPorts,
+		Env:          env,
 		Cmd:          []string{templateName},
 	}, &container.HostConfig{
 		PortBindings: portBindings,
 		Binds:        []string{volume + ":/home/coder"},
+		Mounts:       mounts,
 		Privileged:   true,
 	}, nil, nil, name)
 	if err != nil {
-		return fmt.Errorf("failed to create container: %v", err)
+		return "", fmt.Errorf("failed to create container: %v", err)
 	}
+	// Docker now reports the bindings of the new container itself
+	reservation.Release()
 
+	opts.phase(PhaseStarting)
 	if err := dc.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return fmt.Errorf("failed to start container: %v", err)
+		dc.discard(resp.ID)
+		return "", fmt.Errorf("failed to start container: %v", err)
 	}
+	imageInUse = true
 
 	info := ContainerInfo{
 		ContainerID: resp.ID,
@@ -227,20 +254,227 @@ func (dc *DockerCreate) CreateContainer(name, technology, volume, additionalPort
 		Ports:       portMappings,
 		Volume:      volume,
 		Template:    templateName,
+		Technology:  opts.Runtime,
+		Version:     opts.RuntimeVersion,
+		Arch:        arch,
+		BuildLog:    transcript.String(),
+	}
+	if opts.Devcontainer != nil {
+		info.Source = "devcontainer"
+		info.Devcontainer = opts.Devcontainer.Path
+	}
+	if err := recordContainer(info); err != nil {
+		return resp.ID, err
+	}
+
+	fmt.Printf("Container %s started successfully with ports %v\n", name, portMappings)
+
+	if opts.Scaffold != "" {
+		opts.phase(PhaseRunningTemplate)
+		if err := dc.waitForCodeServer(ctx, resp.ID, portMappings["8080"]); err != nil {
+			if ctx.Err() != nil {
+				dc.discard(resp.ID)
+				imageInUse = false
+				return "", err
+			}
+			return resp.ID, err
+		}
+	}
+
+	return resp.ID, nil
+}
+
+// buildWorkspaceImage builds the shared base image if needed, then the
+// workspace layer on top of it, tagged imageName.
+func (dc *DockerCreate) buildWorkspaceImage(ctx context.Context, opts CreateOptions, imageName, arch, buildID string, transcript *strings.Builder) error {
+	var baseImage, extensions string
+	var err error
+	dockerfile := workspaceDockerfile
+	if opts.Devcontainer != nil {
+		baseImage, err = dc.ensureDevcontainerBase(ctx, opts, buildID, transcript)
+		dockerfile = devcontainerDockerfile
+		extensions = strings.Join(opts.Devcontainer.Customizations.VSCode.Extensions, " ")
+	} else {
+		baseImage, err = dc.ensureBaseImage(ctx, opts, arch, buildID, transcript)
 	}
-	pathName, _ := os.Getwd()
-	transaction, err := NewTransaction(filepath.Join(pathName, "info.yaml"))
 	if err != nil {
-		log.Fatalf("Error beginning transaction: %v", err)
+		return err
+	}
+
+	buildOptions := types.ImageBuildOptions{
+		Dockerfile: dockerfile,
+		Tags:       []string{imageName},
+		BuildArgs: map[string]*string{
+			"BASE_IMAGE":      &baseImage,
+			"EXTENSIONS":      &extensions,
+			"ADDITIONAL_PORT": &opts.Ports,
+			"TEMPLATE_NAME":   &opts.Template,
+		},
+		Labels: map[string]string{
+			labelKind: kindWorkspace,
+		},
+		Remove:   true,
+		Platform: opts.Platform,
+	}
+	return dc.buildImage(ctx, buildOptions, buildID, transcript)
+}
+
+// targetArch returns the architecture the workspace image is built for, in
+// the GOARCH style used by TARGETARCH. It is taken from platform when one
+// is given, and from the Docker daemon otherwise.
+func (dc *DockerCreate) targetArch(ctx context.Context, platform string) (string, error) {
+	if platform != "" {
+		parts := strings.Split(platform, "/")
+		if len(parts) < 2 || parts[0] != "linux" || parts[1] == "" {
+			return "", fmt.Errorf("unsupported platform: %s", platform)
+		}
+		return normalizeArch(parts[1]), nil
+	}
+
+	info, err := dc.cli.Info(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Docker info: %v", err)
+	}
+	return normalizeArch(info.Architecture), nil
+}
+
+// normalizeArch maps the kernel architecture names reported by the daemon
+// to their GOARCH equivalent.
+func normalizeArch(arch string) string {
+	switch strings.ToLower(arch) {
+	case "x86_64", "x86-64", "amd64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "armv7l", "armhf", "arm":
+		return "arm"
+	case "i386", "i686", "386":
+		return "386"
+	default:
+		return strings.ToLower(arch)
+	}
+}
+
+// goTarballArch maps a GOARCH to the architecture in the name of the Go
+// release tarballs, which build 32-bit ARM for ARMv6 and up.
+func goTarballArch(arch string) string {
+	if arch == "arm" {
+		return "armv6l"
+	}
+	return arch
+}
+
+// templateTimeout bounds how long a template scaffold may run before its
+// job fails, so that a scaffold that hangs does not keep the job running.
+const templateTimeout = 30 * time.Minute
+
+// waitForCodeServer blocks until code-server answers on hostPort, which
+// happens once the template scaffold in setup.sh has finished, or until
+// templateTimeout has passed.
+func (dc *DockerCreate) waitForCodeServer(ctx context.Context, containerID, hostPort string) error {
+	url := fmt.Sprintf("http://127.0.0.1:%s/healthz", hostPort)
+	httpClient := &http.Client{Timeout: 2 * time.Second}
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	waitCtx, cancel := context.WithTimeout(ctx, templateTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("template failed: code-server did not start within %v", templateTimeout)
+		case <-ticker.C:
+		}
+
+		info, err := dc.cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %v", err)
+		}
+		if !info.State.Running {
+			return fmt.Errorf("template failed: container exited with code %d", info.State.ExitCode)
+		}
+
+		resp, err := httpClient.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+	}
+}
+
+// discard removes a container left behind by a failed or cancelled
+// creation, together with its store entry.
+func (dc *DockerCreate) discard(containerID string) {
+	if err := dc.cli.ContainerRemove(context.Background(), containerID, container.RemoveOptions{Force: true}); err != nil {
+		log.Printf("Failed to remove container %s: %v", containerID, err)
+	}
+
+	transaction, err := openStore()
+	if err != nil {
+		log.Printf("%v", err)
+		return
 	}
 	defer transaction.rollback()
-	transaction.CreateEntry(info)
-	if err := transaction.commit(); err != nil {
-		log.Fatalf("Error committing transaction: %v", err)
+	if transaction.DeleteEntry(containerID) {
+		if err := transaction.commit(); err != nil {
+			log.Printf("Error committing transaction: %v", err)
+		}
 	}
+}
 
-	fmt.Printf("Container %s started successfully with ports %v\n", name, portMappings)
-	return nil
+// discardImage removes a workspace image left behind by a failed or
+// cancelled build.
+func (dc *DockerCreate) discardImage(imageName string) {
+	if _, err := dc.cli.ImageRemove(context.Background(), imageName, image.RemoveOptions{PruneChildren: true}); err != nil {
+		log.Printf("Failed to remove image %s: %v", imageName, err)
+	}
+}
+
+// readBuildOutput decodes the JSON messages of an image build as they
+// arrive, emitting each one as build progress. It returns the full build
+// transcript, or the error reported by the daemon if the build failed.
+func (dc *DockerCreate) readBuildOutput(buildID string, body io.Reader) (string, error) {
+	var transcript strings.Builder
+	decoder := json.NewDecoder(body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return transcript.String(), fmt.Errorf("error reading build output: %v", err)
+		}
+
+		progress := BuildProgress{
+			BuildID: buildID,
+			Stream:  msg.Stream,
+			Status:  msg.Status,
+		}
+		if msg.Progress != nil {
+			progress.Progress = msg.Progress.String()
+		}
+		if msg.Error != nil {
+			progress.Error = msg.Error.Message
+		}
+		if dc.emit != nil {
+			dc.emit(EventBuildProgress, progress)
+		}
+
+		transcript.WriteString(msg.Stream)
+		if msg.Status != "" {
+			transcript.WriteString(msg.Status + "\n")
+		}
+		if msg.Error != nil {
+			transcript.WriteString(msg.Error.Message + "\n")
+			return transcript.String(), fmt.Errorf("failed to build Docker image: %s", msg.Error.Message)
+		}
+	}
+	return transcript.String(), nil
 }
 
 func (dc *DockerCreate) StopContainer(name string) error {
@@ -258,12 +492,14 @@ func (dc *DockerCreate) StopContainer(name string) error {
 	return nil
 }
 
-func CreateContainer(name, technology, volume, additionalPorts, templateName string) error {
+func CreateContainer(ctx context.Context, opts CreateOptions, emit EventFunc) (string, error) {
 	dc, err := NewDockerCreate()
 	if err != nil {
-		return err
+		return "", err
 	}
-	return dc.CreateContainer(name, technology, volume, additionalPorts, templateName)
+	defer dc.cli.Close()
+	dc.emit = emit
+	return dc.CreateContainer(ctx, opts)
 }
 
 func StopContainer(name string) error {
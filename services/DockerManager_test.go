@@ -0,0 +1,38 @@
+package services
+
+import "testing"
+
+func TestNormalizeArch(t *testing.T) {
+	tests := []struct {
+		arch, want string
+	}{
+		{"x86_64", "amd64"},
+		{"AMD64", "amd64"},
+		{"aarch64", "arm64"},
+		{"armv7l", "arm"},
+		{"armhf", "arm"},
+		{"i686", "386"},
+		{"riscv64", "riscv64"},
+	}
+	for _, tt := range tests {
+		if got := normalizeArch(tt.arch); got != tt.want {
+			t.Errorf("normalizeArch(%q) = %q, want %q", tt.arch, got, tt.want)
+		}
+	}
+}
+
+func TestGoTarballArch(t *testing.T) {
+	tests := []struct {
+		arch, want string
+	}{
+		{"amd64", "amd64"},
+		{"arm64", "arm64"},
+		{"arm", "armv6l"},
+		{"386", "386"},
+	}
+	for _, tt := range tests {
+		if got := goTarballArch(tt.arch); got != tt.want {
+			t.Errorf("goTarballArch(%q) = %q, want %q", tt.arch, got, tt.want)
+		}
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net"
 	"strconv"
 	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 )
@@ -29,151 +31,334 @@ func NewDockerStarter() (*DockerStarter, error) {
 	}, nil
 }
 
-func (ds *DockerStarter) GetContainerInfo(containerName string) (string, string, map[string]string, error) {
-	container, err := ds.cli.ContainerInspect(ds.ctx, containerName)
+func (ds *DockerStarter) CommitContainer(containerName, newImageName string) error {
+	_, err := ds.cli.ContainerCommit(ds.ctx, containerName, container.CommitOptions{Reference: newImageName})
+	return err
+}
+
+func (ds *DockerStarter) RemoveContainer(containerName string) error {
+	return ds.cli.ContainerRemove(ds.ctx, containerName, container.RemoveOptions{Force: true})
+}
+
+// StartContainer starts a stopped container. Workspaces get back the host
+// ports recorded for them in the store; a port is only moved to another one
+// when something else holds it, and every such move is returned. As long as
+// the container's own bindings still hold and no additional ports are
+// requested, it is simply started. Otherwise it is recreated with its full
+// original configuration and the new port bindings.
+func (ds *DockerStarter) StartContainer(containerName string, additionalPorts string) ([]PortChange, error) {
+	if err := ValidatePorts(additionalPorts); err != nil {
+		return nil, err
+	}
+	info, err := ds.cli.ContainerInspect(ds.ctx, containerName)
 	if err != nil {
-		return "", "", nil, fmt.Errorf("failed to inspect container: %v", err)
+		return nil, fmt.Errorf("failed to inspect container: %v", err)
 	}
 
-	image := container.Config.Image
-	var volume string
-	if len(container.Mounts) > 0 {
-		volume = container.Mounts[0].Source
+	if info.State.Running && strings.TrimSpace(additionalPorts) == "" {
+		return nil, nil
 	}
-	labels := container.Config.Labels
 
-	return image, volume, labels, nil
-}
+	name := strings.TrimPrefix(info.Name, "/")
+	reservation, err := reservePorts(ds.ctx, ds.cli, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reservation.Release()
+
+	// The store is only locked to read the record and again to write it
+	// back, not while Docker starts or recreates the container
+	record, recorded, err := lookupRecord(info.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	var stored map[string]string
+	if recorded {
+		stored = record.Ports
+	}
 
-func (ds *DockerStarter) FindAvailablePort(startPort int) int {
-	for port := startPort; ; port++ {
-		addr := fmt.Sprintf(":%d", port)
-		listener, err := net.Listen("tcp", addr)
-		if err == nil {
-			listener.Close()
-			return port
+	portBindings, changes, changed, err := ds.resolvePortBindings(info.HostConfig.PortBindings, stored, additionalPorts, info.State.Running, reservation)
+	if err != nil {
+		return nil, err
+	}
+
+	var portsStr []string
+	for internalPort, bindings := range portBindings {
+		for _, binding := range bindings {
+			portsStr = append(portsStr, fmt.Sprintf("%s:%s", binding.HostPort, internalPort.Port()))
 		}
 	}
-}
+	fmt.Printf("Launching %s on ports %s\n", containerName, strings.Join(portsStr, ","))
 
-func (ds *DockerStarter) CommitContainer(containerName, newImageName string) error {
-	_, err := ds.cli.ContainerCommit(ds.ctx, containerName, container.CommitOptions{Reference: newImageName})
-	return err
+	containerID := info.ID
+	if !changed {
+		if err := ds.cli.ContainerStart(ds.ctx, info.ID, container.StartOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to start container: %v", err)
+		}
+	} else {
+		// Databases keep their state in volumes, committing them would only
+		// overwrite the upstream image tag
+		if info.Config.Labels["type"] != "Database" {
+			if err := ds.CommitContainer(info.ID, info.Config.Image); err != nil {
+				return nil, fmt.Errorf("failed to commit container: %v", err)
+			}
+		}
+		containerID, err = ds.RecreateContainer(info, info.Config.Image, portBindings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run container: %v", err)
+		}
+	}
+
+	if recorded {
+		ports := make(map[string]string)
+		for internalPort, bindings := range portBindings {
+			if len(bindings) > 0 {
+				ports[internalPort.Port()] = bindings[0].HostPort
+			}
+		}
+		err := updateRecord(record.Name, func(updated *ContainerInfo) {
+			updated.ContainerID = containerID
+			updated.Ports = ports
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
 }
 
-func (ds *DockerStarter) RemoveContainer(containerName string) error {
-	return ds.cli.ContainerRemove(ds.ctx, containerName, container.RemoveOptions{Force: true})
+// lookupRecord returns the store entry of the container with the given ID,
+// or of the workspace called name if the ID is not recorded.
+func lookupRecord(id, name string) (*ContainerInfo, bool, error) {
+	transaction, err := openStore()
+	if err != nil {
+		return nil, false, err
+	}
+	defer transaction.rollback()
+
+	record, recorded := transaction.ReadEntryById(id)
+	if !recorded {
+		record, recorded = transaction.ReadEntry(name)
+	}
+	return record, recorded, nil
 }
 
-func (ds *DockerStarter) RunContainer(containerName, image, volume string, ports map[string]string) error {
+// resolvePortBindings gives every container port its recorded host port,
+// or its current one if there is no record, while that port is still free
+// and not reserved by another workspace. Ports that are taken are moved to
+// the next free port and reported as changes. The current ports of a
+// running container are its own and are kept as they are. The requested
+// additional ports are added as well. It also reports whether the bindings
+// differ from the current ones.
+func (ds *DockerStarter) resolvePortBindings(current nat.PortMap, stored map[string]string, additionalPorts string, running bool, reservation *PortReservation) (nat.PortMap, []PortChange, bool, error) {
 	portBindings := nat.PortMap{}
-	exposedPorts := nat.PortSet{}
+	var changes []PortChange
+	changed := false
 
-	for internalPort, externalPort := range ports {
-		port, err := nat.NewPort("tcp", internalPort)
+	assign := func(internalPort nat.Port, hostIP, preferred string) (string, error) {
+		hostPort, err := ParsePort(preferred)
 		if err != nil {
-			return fmt.Errorf("failed to create port: %v", err)
+			hostPort = internalPort.Int()
 		}
-
-		portBindings[port] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: externalPort}}
-		exposedPorts[port] = struct{}{}
+		allocated, err := reservation.Allocate(hostPort)
+		if err != nil {
+			return "", err
+		}
+		availablePort := strconv.Itoa(allocated)
+		if preferred != "" && availablePort != preferred {
+			changes = append(changes, PortChange{
+				Internal: internalPort.Port(),
+				Previous: preferred,
+				Current:  availablePort,
+			})
+		}
+		portBindings[internalPort] = append(portBindings[internalPort], nat.PortBinding{
+			HostIP:   hostIP,
+			HostPort: availablePort,
+		})
+		return availablePort, nil
 	}
 
-	resp, err := ds.cli.ContainerCreate(ds.ctx, &container.Config{
-		Image:        image,
-		ExposedPorts: exposedPorts,
-	}, &container.HostConfig{
-		PortBindings: portBindings,
-		Binds:        []string{volume + ":/home/coder"},
-		Privileged:   true,
-	}, nil, nil, containerName)
-	if err != nil {
-		return fmt.Errorf("failed to create container: %v", err)
+	for internalPort, bindings := range current {
+		for _, binding := range bindings {
+			if running {
+				portBindings[internalPort] = append(portBindings[internalPort], binding)
+				continue
+			}
+			preferred := binding.HostPort
+			if hostPort, ok := stored[internalPort.Port()]; ok {
+				preferred = hostPort
+			}
+			availablePort, err := assign(internalPort, binding.HostIP, preferred)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			if availablePort != binding.HostPort {
+				changed = true
+			}
+		}
 	}
 
-	if err := ds.cli.ContainerStart(ds.ctx, resp.ID, container.StartOptions{}); err != nil {
-		return fmt.Errorf("failed to start container: %v", err)
+	for _, p := range strings.Split(additionalPorts, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		n, err := ParsePort(p)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		port, err := nat.NewPort("tcp", strconv.Itoa(n))
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if _, ok := portBindings[port]; ok {
+			continue
+		}
+		if _, err := assign(port, "0.0.0.0", stored[p]); err != nil {
+			return nil, nil, false, err
+		}
+		changed = true
 	}
 
-	return nil
+	return portBindings, changes, changed, nil
 }
 
-func (ds *DockerStarter) StartContainer(containerName string, additionalPorts string) error {
-	image, volume, labels, err := ds.GetContainerInfo(containerName)
-	if err != nil {
-		return err
+// RecreateContainer replaces a container by a new one with the same name
+// and configuration, running image with the given port bindings. Volumes
+// are carried over, state kept in the container itself must be committed
+// by the caller first. The old container is only removed once the new one
+// runs, on failure it is put back as it was. It returns the ID of the new
+// container.
+func (ds *DockerStarter) RecreateContainer(info types.ContainerJSON, image string, portBindings nat.PortMap) (string, error) {
+	name := strings.TrimPrefix(info.Name, "/")
+
+	config := *info.Config
+	config.Image = image
+	// Let Docker derive the hostname from the new container ID
+	if strings.HasPrefix(info.ID, config.Hostname) {
+		config.Hostname = ""
+	}
+	config.ExposedPorts = nat.PortSet{}
+	for port := range info.Config.ExposedPorts {
+		config.ExposedPorts[port] = struct{}{}
+	}
+	for port := range portBindings {
+		config.ExposedPorts[port] = struct{}{}
 	}
 
-	ports := make(map[string]string)
-	defaultPortAssigned := false
+	hostConfig := *info.HostConfig
+	hostConfig.PortBindings = portBindings
+	hostConfig.Mounts = append([]mount.Mount{}, info.HostConfig.Mounts...)
 
-	for _, v := range labels {
-		if strings.Contains(v, "postgres") {
-			ports["5432"] = strconv.Itoa(ds.FindAvailablePort(5432))
-			defaultPortAssigned = true
-			break
+	// Anonymous volumes are not part of the host config, attach them by
+	// name so their data survives the new container
+	declared := make(map[string]bool)
+	for _, bind := range hostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) > 1 {
+			declared[parts[1]] = true
 		}
-		if strings.Contains(v, "mongo") {
-			ports["27017"] = strconv.Itoa(ds.FindAvailablePort(27017))
-			defaultPortAssigned = true
-			break
+	}
+	for _, m := range hostConfig.Mounts {
+		declared[m.Target] = true
+	}
+	for _, m := range info.Mounts {
+		if m.Type == mount.TypeVolume && m.Name != "" && !declared[m.Destination] {
+			hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+				Type:   mount.TypeVolume,
+				Source: m.Name,
+				Target: m.Destination,
+			})
 		}
 	}
 
-	if !defaultPortAssigned {
-		ports["8080"] = strconv.Itoa(ds.FindAvailablePort(8080))
+	// Only one network can be given at creation, the others are connected
+	// once the container exists
+	var networks []string
+	var endpoints map[string]*network.EndpointSettings
+	if info.NetworkSettings != nil {
+		for networkName := range info.NetworkSettings.Networks {
+			networks = append(networks, networkName)
+		}
+	}
+	if len(networks) > 0 {
+		endpoints = map[string]*network.EndpointSettings{
+			networks[0]: {Aliases: info.NetworkSettings.Networks[networks[0]].Aliases},
+		}
 	}
 
-	if additionalPorts != "" {
-		additionalPortsSlice := strings.Split(additionalPorts, ",")
-		for _, p := range additionalPortsSlice {
-			p = strings.TrimSpace(p)
-			if p == "" {
-				continue
-			}
-			inPort, err := strconv.Atoi(p)
-			if err != nil {
-				log.Printf("Cannot convert Port '%s' to Int: %v", p, err)
-				continue
-			}
-			availablePort := ds.FindAvailablePort(inPort)
-			ports[p] = strconv.Itoa(availablePort)
+	// The old container is set aside rather than removed, so that it can be
+	// put back if the new one cannot be created or started
+	wasRunning := info.State != nil && info.State.Running
+	if wasRunning {
+		if err := ds.cli.ContainerStop(ds.ctx, info.ID, container.StopOptions{}); err != nil {
+			return "", fmt.Errorf("failed to stop container: %v", err)
 		}
-	} else {
-		log.Println("No additional ports specified")
+	}
+	replaced := fmt.Sprintf("%s-replaced-%s", name, generateRandomString(6))
+	if err := ds.cli.ContainerRename(ds.ctx, info.ID, replaced); err != nil {
+		ds.restoreContainer(info.ID, "", name, false, wasRunning)
+		return "", fmt.Errorf("failed to rename container: %v", err)
 	}
 
-	var portsStr []string
-	for internalPort, externalPort := range ports {
-		portsStr = append(portsStr, fmt.Sprintf("%s:%s", externalPort, internalPort))
+	resp, err := ds.cli.ContainerCreate(ds.ctx, &config, &hostConfig, &network.NetworkingConfig{EndpointsConfig: endpoints}, nil, name)
+	if err != nil {
+		ds.restoreContainer(info.ID, "", name, true, wasRunning)
+		return "", fmt.Errorf("failed to create container: %v", err)
 	}
-	fmt.Printf("Launching %s on ports %s and mounting %s\n", containerName, strings.Join(portsStr, ","), volume)
 
-	if err := ds.CommitContainer(containerName, image); err != nil {
-		return fmt.Errorf("failed to commit container: %v", err)
+	for _, networkName := range networks[min(1, len(networks)):] {
+		endpoint := &network.EndpointSettings{Aliases: info.NetworkSettings.Networks[networkName].Aliases}
+		if err := ds.cli.NetworkConnect(ds.ctx, networkName, resp.ID, endpoint); err != nil {
+			ds.restoreContainer(info.ID, resp.ID, name, true, wasRunning)
+			return "", fmt.Errorf("failed to connect network %s: %v", networkName, err)
+		}
 	}
 
-	if err := ds.RemoveContainer(containerName); err != nil {
-		return fmt.Errorf("failed to remove container: %v", err)
+	if err := ds.cli.ContainerStart(ds.ctx, resp.ID, container.StartOptions{}); err != nil {
+		ds.restoreContainer(info.ID, resp.ID, name, true, wasRunning)
+		return "", fmt.Errorf("failed to start container: %v", err)
 	}
 
-	if err := ds.RunContainer(containerName, image, volume, ports); err != nil {
-		return fmt.Errorf("failed to run container: %v", err)
+	if err := ds.RemoveContainer(info.ID); err != nil {
+		log.Printf("Failed to remove replaced container %s: %v", replaced, err)
 	}
+	return resp.ID, nil
+}
 
-	return nil
+// restoreContainer undoes a failed RecreateContainer: the new container, if
+// any, is removed and the old one gets its name and state back.
+func (ds *DockerStarter) restoreContainer(oldID, newID, name string, renamed, wasRunning bool) {
+	if newID != "" {
+		if err := ds.RemoveContainer(newID); err != nil {
+			log.Printf("Failed to remove container %s: %v", shortID(newID), err)
+		}
+	}
+	if renamed {
+		if err := ds.cli.ContainerRename(ds.ctx, oldID, name); err != nil {
+			log.Printf("Failed to rename container %s back to %s: %v", shortID(oldID), name, err)
+			return
+		}
+	}
+	if wasRunning {
+		if err := ds.cli.ContainerStart(ds.ctx, oldID, container.StartOptions{}); err != nil {
+			log.Printf("Failed to restart container %s: %v", name, err)
+		}
+	}
 }
 
-func StartContainer(contName, port string) error {
+func StartContainer(contName, port string) ([]PortChange, error) {
 	ds, err := NewDockerStarter()
 	if err != nil {
 		fmt.Printf("Error creating Docker Starter: %v\n", err)
-		return err
+		return nil, err
 	}
-	err = ds.StartContainer(contName, port)
+	changes, err := ds.StartContainer(contName, port)
 	if err != nil {
 		fmt.Printf("Error starting container: %v\n", err)
-		return err
+		return changes, err
 	}
-	return nil
+	return changes, nil
 }
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// UnusedImage is a Contanize image that no container and no store record
+// refers to anymore.
+type UnusedImage struct {
+	ImageID string   `json:"image_id"`
+	Tags    []string `json:"tags"`
+	Size    int64    `json:"size"`
+	// Reclaimable is the part of Size not shared with other images.
+	Reclaimable int64  `json:"reclaimable"`
+	Created     string `json:"created"`
+}
+
+type ImageGCReport struct {
+	DryRun      bool          `json:"dry_run"`
+	Images      []UnusedImage `json:"images"`
+	Reclaimable int64         `json:"reclaimable"`
+	Removed     []string      `json:"removed"`
+}
+
+// CollectImages finds the workspace images left behind by removed
+// workspaces and, unless dryRun is set, removes them. Images that cannot be
+// removed are skipped. Shared base images are kept, they are managed through
+// ListBaseImages and ClearBaseImages.
+func CollectImages(ctx context.Context, dryRun bool) (*ImageGCReport, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	unused, err := unusedImages(ctx, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImageGCReport{DryRun: dryRun, Images: unused}
+	for _, img := range unused {
+		report.Reclaimable += img.Reclaimable
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for _, img := range unused {
+		if err := removeImage(ctx, cli, img); err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		report.Removed = append(report.Removed, img.ImageID)
+	}
+	return report, nil
+}
+
+// RemoveWorkspaceImage removes the image of a removed workspace, unless
+// another container or store record still refers to it.
+func RemoveWorkspaceImage(ctx context.Context, ref string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to inspect image: %v", err)
+	}
+
+	unused, err := unusedImages(ctx, cli)
+	if err != nil {
+		return err
+	}
+	for _, img := range unused {
+		if img.ImageID == inspect.ID {
+			return removeImage(ctx, cli, img)
+		}
+	}
+	log.Printf("Keeping image %s, it is still in use", ref)
+	return nil
+}
+
+// unusedImages lists the Contanize images, other than base images, that no
+// container and no store record refers to.
+func unusedImages(ctx context.Context, cli *client.Client) ([]UnusedImage, error) {
+	images, err := cli.ImageList(ctx, image.ListOptions{
+		Filters:    filters.NewArgs(filters.Arg("label", "createdBy=Contanize")),
+		SharedSize: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %v", err)
+	}
+
+	referenced, err := referencedImages(ctx, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	var unused []UnusedImage
+	for _, img := range images {
+		if img.Labels[labelKind] == kindBase || img.Containers > 0 || referenced[img.ID] {
+			continue
+		}
+		inUse := false
+		for _, tag := range img.RepoTags {
+			if referenced[tag] {
+				inUse = true
+				break
+			}
+		}
+		if inUse {
+			continue
+		}
+
+		reclaimable := img.Size
+		if img.SharedSize > 0 {
+			reclaimable -= img.SharedSize
+		}
+		unused = append(unused, UnusedImage{
+			ImageID:     img.ID,
+			Tags:        img.RepoTags,
+			Size:        img.Size,
+			Reclaimable: reclaimable,
+			Created:     time.Unix(img.Created, 0).Format(time.RFC3339),
+		})
+	}
+	return unused, nil
+}
+
+// referencedImages returns the image IDs and references used by any
+// container, running or not, or recorded in the store, snapshots included.
+func referencedImages(ctx context.Context, cli *client.Client) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+	for _, c := range containers {
+		referenced[c.ImageID] = true
+		addImageRef(referenced, c.Image)
+	}
+
+	transaction, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.rollback()
+	for _, record := range *transaction.db {
+		addImageRef(referenced, record.Image)
+		if record.Source == "devcontainer" {
+			addImageRef(referenced, devcontainerImage(record.Name))
+		}
+		for _, snapshot := range record.Snapshots {
+			referenced[snapshot.ImageID] = true
+			addImageRef(referenced, snapshot.Tag)
+		}
+	}
+	return referenced, nil
+}
+
+// removeImage removes every tag of img, which deletes it once the last tag
+// is gone, or the image itself if it is untagged.
+func removeImage(ctx context.Context, cli *client.Client, img UnusedImage) error {
+	var refs []string
+	for _, tag := range img.Tags {
+		if tag != "<none>:<none>" {
+			refs = append(refs, tag)
+		}
+	}
+	if len(refs) == 0 {
+		refs = []string{img.ImageID}
+	}
+	for _, ref := range refs {
+		if _, err := cli.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil && !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed to remove image %s: %v", ref, err)
+		}
+	}
+	return nil
+}
+
+// addImageRef adds ref to referenced, together with its :latest form if it
+// has no tag, as image tags are always listed with one.
+func addImageRef(referenced map[string]bool, ref string) {
+	if ref == "" {
+		return
+	}
+	referenced[ref] = true
+	if !hasTag(ref) {
+		referenced[ref+":latest"] = true
+	}
+}
+
+// hasTag reports whether an image reference carries a tag, ignoring the
+// port of a registry host.
+func hasTag(ref string) bool {
+	for i := len(ref) - 1; i >= 0; i-- {
+		switch ref[i] {
+		case ':':
+			return true
+		case '/':
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventJobUpdate is emitted every time a job changes phase.
+const EventJobUpdate = "job:update"
+
+// finishedJobTTL is how long a finished job can still be looked up before
+// it is dropped.
+const finishedJobTTL = time.Hour
+
+type JobPhase string
+
+const (
+	PhaseQueued          JobPhase = "queued"
+	PhaseBuilding        JobPhase = "building"
+	PhaseCreating        JobPhase = "creating"
+	PhaseStarting        JobPhase = "starting"
+	PhaseRunningTemplate JobPhase = "running_template"
+	PhaseDone            JobPhase = "done"
+	PhaseFailed          JobPhase = "failed"
+	PhaseCancelled       JobPhase = "cancelled"
+)
+
+type Job struct {
+	ID          string   `json:"id"`
+	Kind        string   `json:"kind"`
+	Name        string   `json:"name"`
+	Phase       JobPhase `json:"phase"`
+	ContainerID string   `json:"containerId"`
+	Error       string   `json:"error"`
+	StartedAt   string   `json:"startedAt"`
+	FinishedAt  string   `json:"finishedAt"`
+
+	cancel   context.CancelFunc
+	finished time.Time
+}
+
+// Finished reports whether the job reached a final phase.
+func (j *Job) Finished() bool {
+	return j.Phase == PhaseDone || j.Phase == PhaseFailed || j.Phase == PhaseCancelled
+}
+
+// JobFunc does the work of a job. It reports its progress through
+// setPhase and returns the ID of the container it produced.
+type JobFunc func(ctx context.Context, jobID string, setPhase func(JobPhase)) (string, error)
+
+type JobManager struct {
+	emit EventFunc
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewJobManager(emit EventFunc) *JobManager {
+	return &JobManager{
+		emit: emit,
+		jobs: make(map[string]*Job),
+	}
+}
+
+// Start runs fn in the background and returns the ID of the new job.
+func (jm *JobManager) Start(ctx context.Context, kind, name string, fn JobFunc) string {
+	jobCtx, cancel := context.WithCancel(ctx)
+	job := &Job{
+		ID:        "job-" + generateRandomString(12),
+		Kind:      kind,
+		Name:      name,
+		Phase:     PhaseQueued,
+		StartedAt: time.Now().Format(time.RFC3339),
+		cancel:    cancel,
+	}
+
+	jm.mu.Lock()
+	jm.prune()
+	jm.jobs[job.ID] = job
+	jm.mu.Unlock()
+	jm.publish(job)
+
+	go func() {
+		defer cancel()
+
+		containerID, err := fn(jobCtx, job.ID, func(phase JobPhase) {
+			jm.update(job, func() { job.Phase = phase })
+		})
+
+		jm.update(job, func() {
+			job.ContainerID = containerID
+			job.finished = time.Now()
+			job.FinishedAt = job.finished.Format(time.RFC3339)
+			// A cancel that arrives once fn has succeeded is too late, the
+			// outcome is that of fn
+			switch {
+			case err == nil:
+				job.Phase = PhaseDone
+			case jobCtx.Err() == context.Canceled:
+				job.Phase = PhaseCancelled
+				job.Error = "cancelled"
+			default:
+				job.Phase = PhaseFailed
+				job.Error = err.Error()
+			}
+		})
+		if err != nil {
+			log.Printf("Job %s (%s %s) failed: %v", job.ID, kind, name, err)
+		}
+	}()
+
+	return job.ID
+}
+
+func (jm *JobManager) Get(id string) (Job, error) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	job, ok := jm.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("job %s not found", id)
+	}
+	return *job, nil
+}
+
+// List returns all known jobs, most recent first.
+func (jm *JobManager) List() []Job {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	jm.prune()
+	jobs := make([]Job, 0, len(jm.jobs))
+	for _, job := range jm.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt > jobs[j].StartedAt
+	})
+	return jobs
+}
+
+// Cancel stops a running job. Finished jobs cannot be cancelled.
+func (jm *JobManager) Cancel(id string) error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	job, ok := jm.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	if job.Finished() {
+		return fmt.Errorf("job %s has already finished", id)
+	}
+	job.cancel()
+	return nil
+}
+
+// CancelAll stops every running job.
+func (jm *JobManager) CancelAll() {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	for _, job := range jm.jobs {
+		if !job.Finished() {
+			job.cancel()
+		}
+	}
+}
+
+// prune drops the jobs that finished more than finishedJobTTL ago. The
+// caller holds jm.mu.
+func (jm *JobManager) prune() {
+	for id, job := range jm.jobs {
+		if job.Finished() && time.Since(job.finished) > finishedJobTTL {
+			delete(jm.jobs, id)
+		}
+	}
+}
+
+func (jm *JobManager) update(job *Job, change func()) {
+	jm.mu.Lock()
+	change()
+	jm.mu.Unlock()
+	jm.publish(job)
+}
+
+func (jm *JobManager) publish(job *Job) {
+	jm.mu.Lock()
+	snapshot := *job
+	jm.mu.Unlock()
+	if jm.emit != nil {
+		jm.emit(EventJobUpdate, snapshot)
+	}
+}
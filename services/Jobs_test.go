@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobOutcome(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		phase JobPhase
+	}{
+		{"success", nil, PhaseDone},
+		{"failure", errors.New("build failed"), PhaseFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jm := NewJobManager(nil)
+			id := jm.Start(context.Background(), "create", "web", func(ctx context.Context, jobID string, setPhase func(JobPhase)) (string, error) {
+				return "abc", tt.err
+			})
+			job := waitForJob(t, jm, id)
+			if job.Phase != tt.phase {
+				t.Errorf("job phase = %s, want %s", job.Phase, tt.phase)
+			}
+		})
+	}
+}
+
+func TestJobPrune(t *testing.T) {
+	jm := NewJobManager(nil)
+	id := jm.Start(context.Background(), "create", "web", func(ctx context.Context, jobID string, setPhase func(JobPhase)) (string, error) {
+		return "abc", nil
+	})
+	waitForJob(t, jm, id)
+	if jobs := jm.List(); len(jobs) != 1 {
+		t.Fatalf("List() = %d jobs right after finishing, want 1", len(jobs))
+	}
+
+	jm.mu.Lock()
+	jm.jobs[id].finished = time.Now().Add(-finishedJobTTL - time.Minute)
+	jm.mu.Unlock()
+	if jobs := jm.List(); len(jobs) != 0 {
+		t.Errorf("List() = %d jobs, want the expired one pruned", len(jobs))
+	}
+	if _, err := jm.Get(id); err == nil {
+		t.Error("Get() found a pruned job")
+	}
+}
+
+func waitForJob(t *testing.T, jm *JobManager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := jm.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Finished() {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
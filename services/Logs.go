@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Events emitted while a container log stream is active.
+const (
+	EventLogChunk = "logs:chunk"
+	EventLogEnd   = "logs:end"
+)
+
+type LogChunk struct {
+	ContainerID string `json:"containerId"`
+	Stream      string `json:"stream"`
+	Data        string `json:"data"`
+}
+
+type LogEnd struct {
+	ContainerID string `json:"containerId"`
+	Error       string `json:"error"`
+}
+
+type LogStreamer struct {
+	emit    EventFunc
+	mu      sync.Mutex
+	streams map[string]context.CancelFunc
+}
+
+func NewLogStreamer(emit EventFunc) *LogStreamer {
+	return &LogStreamer{
+		emit:    emit,
+		streams: make(map[string]context.CancelFunc),
+	}
+}
+
+// logWriter forwards everything written to it as log chunk events.
+type logWriter struct {
+	emit        EventFunc
+	containerID string
+	stream      string
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.emit(EventLogChunk, LogChunk{
+		ContainerID: w.containerID,
+		Stream:      w.stream,
+		Data:        string(p),
+	})
+	return len(p), nil
+}
+
+// Stream starts emitting the logs of containerID as events. An already
+// running stream for the same container is replaced.
+func (ls *LogStreamer) Stream(ctx context.Context, containerID string, follow bool, tail, since string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		cli.Close()
+		return fmt.Errorf("failed to inspect container: %v", err)
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	body, err := cli.ContainerLogs(streamCtx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Tail:       tail,
+		Since:      since,
+	})
+	if err != nil {
+		cancel()
+		cli.Close()
+		return fmt.Errorf("failed to read container logs: %v", err)
+	}
+
+	ls.mu.Lock()
+	if previous, ok := ls.streams[containerID]; ok {
+		previous()
+	}
+	ls.streams[containerID] = cancel
+	ls.mu.Unlock()
+
+	go func() {
+		defer cli.Close()
+		defer body.Close()
+		defer ls.release(containerID, streamCtx)
+
+		stdout := &logWriter{emit: ls.emit, containerID: containerID, stream: "stdout"}
+		stderr := &logWriter{emit: ls.emit, containerID: containerID, stream: "stderr"}
+
+		// Containers with a TTY produce a raw stream that is not multiplexed
+		if info.Config != nil && info.Config.Tty {
+			_, err = io.Copy(stdout, body)
+		} else {
+			_, err = stdcopy.StdCopy(stdout, stderr, body)
+		}
+
+		end := LogEnd{ContainerID: containerID}
+		if err != nil && streamCtx.Err() == nil {
+			log.Printf("Log stream for %s failed: %v", containerID, err)
+			end.Error = err.Error()
+		}
+		ls.emit(EventLogEnd, end)
+	}()
+
+	return nil
+}
+
+// Stop ends the log stream of containerID, if any.
+func (ls *LogStreamer) Stop(containerID string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if cancel, ok := ls.streams[containerID]; ok {
+		cancel()
+		delete(ls.streams, containerID)
+	}
+}
+
+// StopAll ends every active log stream.
+func (ls *LogStreamer) StopAll() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for id, cancel := range ls.streams {
+		cancel()
+		delete(ls.streams, id)
+	}
+}
+
+func (ls *LogStreamer) release(containerID string, streamCtx context.Context) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	// Only forget the stream if it has not been replaced in the meantime
+	if cancel, ok := ls.streams[containerID]; ok && streamCtx.Err() == nil {
+		cancel()
+		delete(ls.streams, containerID)
+	}
+}
+
+// ExportLogs writes the logs of containerID between since and until to
+// filename, with timestamps. Empty bounds are left open.
+func ExportLogs(containerID, since, until, filename string) error {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %v", err)
+	}
+
+	body, err := cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Since:      since,
+		Until:      until,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read container logs: %v", err)
+	}
+	defer body.Close()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(f, body)
+	} else {
+		_, err = stdcopy.StdCopy(f, f, body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write log file: %v", err)
+	}
+
+	return nil
+}
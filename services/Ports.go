@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+// EventPortsChanged is emitted when a workspace could not get its recorded
+// host ports back and had to move to other ones.
+const EventPortsChanged = "ports:changed"
+
+// PortChange describes a container port whose host port moved.
+type PortChange struct {
+	Internal string `json:"internal"`
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+type PortsChanged struct {
+	Name    string       `json:"name"`
+	Changes []PortChange `json:"changes"`
+}
+
+// ReservedPorts returns the host ports recorded for every workspace except
+// the one called exclude. A stopped workspace keeps its ports reserved so
+// that it gets the same URLs back when it is started again.
+func (t *Transaction) ReservedPorts(exclude string) map[int]bool {
+	reserved := make(map[int]bool)
+	for _, info := range *t.db {
+		if info.Name == exclude {
+			continue
+		}
+		for _, hostPort := range info.Ports {
+			if port, err := strconv.Atoi(hostPort); err == nil {
+				reserved[port] = true
+			}
+		}
+	}
+	return reserved
+}
+
+// storeReservedPorts returns the host ports reserved in the store by
+// workspaces other than exclude.
+func storeReservedPorts(exclude string) (map[int]bool, error) {
+	transaction, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.rollback()
+	return transaction.ReservedPorts(exclude), nil
+}
+
+// maxPort is the highest TCP port number.
+const maxPort = 65535
+
+func portError(port string) error {
+	return &InputError{Field: "port", Reason: fmt.Sprintf("%q is not a number between 1 and %d", port, maxPort)}
+}
+
+// ParsePort parses a port number, rejecting anything outside 1-65535.
+func ParsePort(port string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(port))
+	if err != nil || n < 1 || n > maxPort {
+		return 0, portError(strings.TrimSpace(port))
+	}
+	return n, nil
+}
+
+// ValidatePorts checks a comma separated list of ports as taken by the
+// create and start calls. Empty entries are ignored.
+func ValidatePorts(ports string) error {
+	for _, port := range strings.Split(ports, ",") {
+		if strings.TrimSpace(port) == "" {
+			continue
+		}
+		if _, err := ParsePort(port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// heldPorts are the host ports handed out to containers that are still
+// being created. Probing a port and binding it are not atomic, so a port
+// stays held until Docker has recorded it for its container.
+var (
+	heldPortsMu sync.Mutex
+	heldPorts   = make(map[int]bool)
+)
+
+// PortReservation hands out host ports for one container and holds them
+// until Release is called once the container has been created.
+type PortReservation struct {
+	taken map[int]bool
+	ports []int
+}
+
+// reservePorts starts a port reservation for the container called name, or
+// for a new container if name is empty. Ports recorded in the store for
+// other workspaces and ports published by any other container, running or
+// stopped, are never handed out.
+func reservePorts(ctx context.Context, cli *client.Client, name string) (*PortReservation, error) {
+	taken, err := storeReservedPorts(name)
+	if err != nil {
+		return nil, err
+	}
+	published, err := publishedPorts(ctx, cli, name)
+	if err != nil {
+		return nil, err
+	}
+	for port := range published {
+		taken[port] = true
+	}
+	return &PortReservation{taken: taken}, nil
+}
+
+// publishedPorts returns the host ports bound by every container except the
+// one called exclude. Stopped containers do not hold their ports, so their
+// bindings are read from their configuration.
+func publishedPorts(ctx context.Context, cli *client.Client, exclude string) (map[int]bool, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	published := make(map[int]bool)
+	for _, c := range containers {
+		if exclude != "" && len(c.Names) > 0 && strings.TrimPrefix(c.Names[0], "/") == exclude {
+			continue
+		}
+		for _, port := range c.Ports {
+			if port.PublicPort != 0 {
+				published[int(port.PublicPort)] = true
+			}
+		}
+		if c.State == "running" {
+			continue
+		}
+		info, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			// Removed since it was listed
+			continue
+		}
+		for _, bindings := range info.HostConfig.PortBindings {
+			for _, binding := range bindings {
+				if port, err := strconv.Atoi(binding.HostPort); err == nil {
+					published[port] = true
+				}
+			}
+		}
+	}
+	return published, nil
+}
+
+// Allocate returns preferred if it is free, otherwise the next port after
+// it that is, and holds it until Release.
+func (r *PortReservation) Allocate(preferred int) (int, error) {
+	if preferred < 1 || preferred > maxPort {
+		return 0, portError(strconv.Itoa(preferred))
+	}
+
+	heldPortsMu.Lock()
+	defer heldPortsMu.Unlock()
+
+	for port := preferred; port <= maxPort; port++ {
+		if r.taken[port] || heldPorts[port] || isPortInUse(port) {
+			continue
+		}
+		heldPorts[port] = true
+		r.taken[port] = true
+		r.ports = append(r.ports, port)
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free host port between %d and %d", preferred, maxPort)
+}
+
+// Bind allocates a host port for each of the given container ports,
+// preferring the same port number on the host, and returns them as the
+// store port map together with the bindings and exposed ports of the
+// container configuration.
+func (r *PortReservation) Bind(internalPorts []string) (map[string]string, nat.PortMap, nat.PortSet, error) {
+	sorted := append([]string{}, internalPorts...)
+	sort.Strings(sorted)
+
+	portMappings := make(map[string]string)
+	portBindings := nat.PortMap{}
+	exposedPorts := nat.PortSet{}
+	for _, internalPort := range sorted {
+		portInt, err := strconv.Atoi(internalPort)
+		if err != nil {
+			continue
+		}
+		allocated, err := r.Allocate(portInt)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		externalPort := strconv.Itoa(allocated)
+		portMappings[internalPort] = externalPort
+		portBindings[nat.Port(internalPort+"/tcp")] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: externalPort}}
+		exposedPorts[nat.Port(internalPort+"/tcp")] = struct{}{}
+	}
+	return portMappings, portBindings, exposedPorts, nil
+}
+
+// Release gives up the ports held by the reservation. It is safe to call
+// more than once.
+func (r *PortReservation) Release() {
+	heldPortsMu.Lock()
+	defer heldPortsMu.Unlock()
+
+	for _, port := range r.ports {
+		delete(heldPorts, port)
+	}
+	r.ports = nil
+}
+
+func isPortInUse(port int) bool {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return true
+	}
+	ln.Close()
+	return false
+}
@@ -0,0 +1,52 @@
+package services
+
+import "testing"
+
+func TestValidatePorts(t *testing.T) {
+	tests := []struct {
+		ports   string
+		wantErr bool
+	}{
+		{"", false},
+		{"3000", false},
+		{"3000, 8000,", false},
+		{"1,65535", false},
+		{"0", true},
+		{"65536", true},
+		{"-1", true},
+		{"3000,http", true},
+		{"3000-3005", true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePorts(tt.ports); (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePorts(%q) error = %v, want error %v", tt.ports, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAllocateBounds(t *testing.T) {
+	tests := []struct {
+		name      string
+		preferred int
+		taken     []int
+		wantErr   bool
+	}{
+		{"zero", 0, nil, true},
+		{"negative", -80, nil, true},
+		{"above range", 65536, nil, true},
+		{"nothing left", 65533, []int{65533, 65534, 65535}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &PortReservation{taken: make(map[int]bool)}
+			for _, port := range tt.taken {
+				r.taken[port] = true
+			}
+			defer r.Release()
+			port, err := r.Allocate(tt.preferred)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Allocate(%d) = %d, %v, want error %v", tt.preferred, port, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+)
+
+// RebuildOptions adjusts how a workspace is rebuilt. The zero value
+// rebuilds it as it was recorded.
+type RebuildOptions struct {
+	// Version replaces the recorded runtime version when set.
+	Version string `json:"version"`
+	// Platform optionally selects the build platform, such as linux/arm64.
+	Platform string `json:"platform"`
+	// Refresh rebuilds the base image without cache, picking up new
+	// releases of the runtime and code-server.
+	Refresh bool `json:"refresh"`
+}
+
+// RebuildWorkspace builds a new image for the workspace called name from
+// its recorded runtime and template, then swaps its container for one
+// running the new image. The workspace folder, ports, labels and the rest
+// of the container configuration are kept, and the template scaffold is
+// not run again. The existing container is left untouched if the build
+// fails. It returns the ID of the new container.
+func (dc *DockerCreate) RebuildWorkspace(ctx context.Context, name string, options RebuildOptions, buildID string, onPhase func(JobPhase)) (string, error) {
+	info, err := dc.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %v", err)
+	}
+	record, err := findRecord(info.ID)
+	if err != nil {
+		return "", err
+	}
+
+	opts := CreateOptions{
+		Name:           record.Name,
+		Runtime:        record.Technology,
+		RuntimeVersion: record.Version,
+		Volume:         record.Volume,
+		Template:       record.Template,
+		Platform:       options.Platform,
+		Refresh:        options.Refresh,
+		BuildID:        buildID,
+		OnPhase:        onPhase,
+	}
+	if opts.Template == "" {
+		opts.Template = "none"
+	}
+
+	var additionalPorts []string
+	for internalPort := range record.Ports {
+		if internalPort != "8080" {
+			additionalPorts = append(additionalPorts, internalPort)
+		}
+	}
+	opts.Ports = strings.Join(additionalPorts, ",")
+
+	if record.Source == "devcontainer" {
+		opts.Devcontainer, err = LoadDevcontainer(record.Devcontainer, record.Volume)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// Records written before runtimes were recorded only know their
+		// template
+		if opts.Runtime == "" && opts.Template != "none" {
+			template, err := FindTemplate(opts.Template)
+			if err != nil {
+				return "", fmt.Errorf("%s has no recorded runtime: %v", name, err)
+			}
+			opts.Runtime, opts.RuntimeVersion = template.Technology, template.Version
+		}
+		if options.Version != "" {
+			opts.RuntimeVersion = options.Version
+		}
+		opts.Runtime, opts.RuntimeVersion, err = ResolveRuntime(opts.Runtime, opts.RuntimeVersion)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	arch, err := dc.targetArch(ctx, opts.Platform)
+	if err != nil {
+		return "", err
+	}
+
+	imageName := fmt.Sprintf("%s-%s", record.Name, generateRandomString(8))
+	if buildID == "" {
+		opts.BuildID = imageName
+	}
+
+	opts.phase(PhaseBuilding)
+	var transcript strings.Builder
+	if err := dc.buildWorkspaceImage(ctx, opts, imageName, arch, opts.BuildID, &transcript); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		dc.discardImage(imageName)
+		return "", err
+	}
+
+	opts.phase(PhaseStarting)
+
+	// The scaffold has already run against the workspace folder, the new
+	// container must not run it again. Labels describing the old image are
+	// dropped so that the ones of the new image show through.
+	config := *info.Config
+	config.Env = nil
+	for _, env := range info.Config.Env {
+		if !strings.HasPrefix(env, "TEMPLATE_SCAFFOLD=") {
+			config.Env = append(config.Env, env)
+		}
+	}
+	config.Labels = make(map[string]string)
+	for key, value := range info.Config.Labels {
+		switch key {
+		case labelRuntime, labelVersion, labelArch, labelHash:
+		default:
+			config.Labels[key] = value
+		}
+	}
+	info.Config = &config
+
+	// The recorded ports are only reused while nobody else took them, as
+	// when the workspace is started
+	reservation, err := reservePorts(ctx, dc.cli, record.Name)
+	if err != nil {
+		dc.discardImage(imageName)
+		return "", err
+	}
+	defer reservation.Release()
+	var missingPorts []string
+	for internalPort := range record.Ports {
+		if _, ok := info.HostConfig.PortBindings[nat.Port(internalPort+"/tcp")]; !ok {
+			missingPorts = append(missingPorts, internalPort)
+		}
+	}
+	ds := &DockerStarter{cli: dc.cli, ctx: context.Background()}
+	portBindings, changes, _, err := ds.resolvePortBindings(info.HostConfig.PortBindings, record.Ports, strings.Join(missingPorts, ","), info.State.Running, reservation)
+	if err != nil {
+		dc.discardImage(imageName)
+		return "", err
+	}
+
+	// Once started, the swap must not be interrupted halfway
+	containerID, err := ds.RecreateContainer(info, imageName, portBindings)
+	if err != nil {
+		dc.discardImage(imageName)
+		return "", fmt.Errorf("failed to swap container: %v", err)
+	}
+	reservation.Release()
+	if len(changes) > 0 && dc.emit != nil {
+		dc.emit(EventPortsChanged, PortsChanged{Name: record.Name, Changes: changes})
+	}
+
+	ports := make(map[string]string)
+	for internalPort, bindings := range portBindings {
+		if len(bindings) > 0 {
+			ports[internalPort.Port()] = bindings[0].HostPort
+		}
+	}
+	err = updateRecord(record.Name, func(updated *ContainerInfo) {
+		updated.ContainerID = containerID
+		updated.Image = imageName
+		updated.Ports = ports
+		updated.Technology = opts.Runtime
+		updated.Version = opts.RuntimeVersion
+		updated.Arch = arch
+		updated.BuildLog = transcript.String()
+	})
+	if err != nil {
+		return containerID, err
+	}
+
+	if err := RemoveWorkspaceImage(ctx, record.Image); err != nil {
+		log.Printf("Failed to remove previous image of %s: %v", record.Name, err)
+	}
+	return containerID, nil
+}
+
+func RebuildWorkspace(ctx context.Context, name string, options RebuildOptions, buildID string, onPhase func(JobPhase), emit EventFunc) (string, error) {
+	dc, err := NewDockerCreate()
+	if err != nil {
+		return "", err
+	}
+	defer dc.cli.Close()
+	dc.emit = emit
+	return dc.RebuildWorkspace(ctx, name, options, buildID, onPhase)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// EventStoreReconciled is emitted with a ReconcileReport whenever a
+// reconciliation changed the store.
+const EventStoreReconciled = "store:reconciled"
+
+// reconcileDelay debounces bursts of Docker events, such as the destroy and
+// create pair of a recreated container, into a single reconciliation.
+const reconcileDelay = 2 * time.Second
+
+// Actions of a ReconcileChange.
+const (
+	ReconcileRemoved = "removed"
+	ReconcileAdopted = "adopted"
+	ReconcileUpdated = "updated"
+)
+
+type ReconcileChange struct {
+	Action      string `json:"action"`
+	Name        string `json:"name"`
+	ContainerID string `json:"container_id"`
+	Detail      string `json:"detail,omitempty"`
+}
+
+type ReconcileReport struct {
+	Changes []ReconcileChange `json:"changes"`
+}
+
+// ReconcileStore brings the workspace store in line with the containers
+// Docker actually has. Records of containers that no longer exist are
+// removed, records whose container was recreated under a new ID are
+// updated, and Contanize workspaces without a record are adopted.
+func ReconcileStore(ctx context.Context) (*ReconcileReport, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	// The containers are listed with the store locked, so that no create or
+	// remove can commit between the two and be reconciled against a stale
+	// list
+	transaction, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.rollback()
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "createdBy=Contanize")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	byID := make(map[string]types.Container)
+	byName := make(map[string]types.Container)
+	for _, c := range containers {
+		// Databases are not workspaces and have no record
+		if c.Labels["type"] == "Database" || len(c.Names) == 0 {
+			continue
+		}
+		byID[c.ID] = c
+		byName[strings.TrimPrefix(c.Names[0], "/")] = c
+	}
+
+	report := &ReconcileReport{}
+	claimed := make(map[string]bool)
+	var kept Database
+	for _, record := range *transaction.db {
+		if c, ok := byID[record.ContainerID]; ok && !claimed[c.ID] {
+			claimed[c.ID] = true
+			kept = append(kept, record)
+			continue
+		}
+		if c, ok := byName[record.Name]; ok && !claimed[c.ID] {
+			claimed[c.ID] = true
+			report.Changes = append(report.Changes, ReconcileChange{
+				Action:      ReconcileUpdated,
+				Name:        record.Name,
+				ContainerID: c.ID,
+				Detail:      fmt.Sprintf("container ID changed from %s", shortID(record.ContainerID)),
+			})
+			record.ContainerID = c.ID
+			kept = append(kept, record)
+			continue
+		}
+		report.Changes = append(report.Changes, ReconcileChange{
+			Action:      ReconcileRemoved,
+			Name:        record.Name,
+			ContainerID: record.ContainerID,
+			Detail:      "container no longer exists",
+		})
+	}
+
+	for _, c := range containers {
+		if _, ok := byID[c.ID]; !ok || claimed[c.ID] {
+			continue
+		}
+		info, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			// Removed since it was listed
+			continue
+		}
+		record := adoptedRecord(info)
+		kept = append(kept, record)
+		report.Changes = append(report.Changes, ReconcileChange{
+			Action:      ReconcileAdopted,
+			Name:        record.Name,
+			ContainerID: record.ContainerID,
+			Detail:      "container had no record",
+		})
+	}
+
+	if len(report.Changes) == 0 {
+		return report, nil
+	}
+	if kept == nil {
+		kept = Database{}
+	}
+	*transaction.db = kept
+	if err := transaction.commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return report, nil
+}
+
+// adoptedRecord rebuilds the store record of a workspace container from
+// its configuration and the labels inherited from its images.
+func adoptedRecord(info types.ContainerJSON) ContainerInfo {
+	record := ContainerInfo{
+		ContainerID: info.ID,
+		Name:        strings.TrimPrefix(info.Name, "/"),
+		Image:       info.Config.Image,
+		Ports:       make(map[string]string),
+		Technology:  info.Config.Labels[labelRuntime],
+		Version:     info.Config.Labels[labelVersion],
+		Arch:        info.Config.Labels[labelArch],
+	}
+	if len(info.Config.Cmd) > 0 {
+		record.Template = info.Config.Cmd[0]
+	}
+	for internalPort, bindings := range info.HostConfig.PortBindings {
+		if len(bindings) > 0 {
+			record.Ports[internalPort.Port()] = bindings[0].HostPort
+		}
+	}
+	for _, bind := range info.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) > 1 && parts[1] == workspaceFolder {
+			record.Volume = parts[0]
+		}
+	}
+	return record
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// Reconciler reconciles the store at startup and again shortly after
+// Docker reports containers being created or destroyed.
+type Reconciler struct {
+	emit    EventFunc
+	trigger chan struct{}
+	mu      sync.Mutex
+}
+
+func NewReconciler(emit EventFunc) *Reconciler {
+	return &Reconciler{
+		emit:    emit,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// Notify schedules a reconciliation if the event named name may have
+// changed the set of workspace containers.
+func (r *Reconciler) Notify(name string) {
+	if name != EventContainerCreated && name != EventContainerDestroyed {
+		return
+	}
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run reconciles once and then on every notification until ctx is
+// cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	r.reconcile(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.trigger:
+		}
+
+		// Let the burst of events settle first
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconcileDelay):
+		}
+		select {
+		case <-r.trigger:
+		default:
+		}
+		r.reconcile(ctx)
+	}
+}
+
+// Reconcile runs a reconciliation right away and returns its report.
+func (r *Reconciler) Reconcile(ctx context.Context) (*ReconcileReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, err := ReconcileStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Changes) > 0 {
+		r.emit(EventStoreReconciled, report)
+	}
+	return report, nil
+}
+
+func (r *Reconciler) reconcile(ctx context.Context) {
+	if _, err := r.Reconcile(ctx); err != nil {
+		log.Printf("Failed to reconcile store: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+type RuntimeVersion struct {
+	Version string `json:"version"`
+	Label   string `json:"label"`
+}
+
+// Runtime is a language toolchain that can be installed into a workspace
+// image. The ID and version are passed to the dockerfile as the RUNTIME and
+// RUNTIME_VERSION build args.
+type Runtime struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Default  string           `json:"default"`
+	Versions []RuntimeVersion `json:"versions"`
+}
+
+var runtimeCatalog = []Runtime{
+	{
+		ID:      "node",
+		Name:    "Node.js",
+		Default: "lts",
+		Versions: []RuntimeVersion{
+			{Version: "lts", Label: "LTS"},
+			{Version: "22", Label: "Node 22"},
+			{Version: "21", Label: "Node 21"},
+			{Version: "20", Label: "Node 20"},
+			{Version: "18", Label: "Node 18"},
+		},
+	},
+	{
+		ID:      "python",
+		Name:    "Python",
+		Default: "3",
+		Versions: []RuntimeVersion{
+			{Version: "3", Label: "Python 3"},
+		},
+	},
+	{
+		ID:      "go",
+		Name:    "Go",
+		Default: "1.22.5",
+		Versions: []RuntimeVersion{
+			{Version: "1.23.2", Label: "Go 1.23"},
+			{Version: "1.22.5", Label: "Go 1.22"},
+			{Version: "1.21.13", Label: "Go 1.21"},
+		},
+	},
+	{
+		ID:      "rust",
+		Name:    "Rust",
+		Default: "stable",
+		Versions: []RuntimeVersion{
+			{Version: "stable", Label: "Stable"},
+			{Version: "beta", Label: "Beta"},
+			{Version: "nightly", Label: "Nightly"},
+		},
+	},
+	{
+		ID:      "java",
+		Name:    "Java",
+		Default: "17",
+		Versions: []RuntimeVersion{
+			{Version: "21", Label: "OpenJDK 21"},
+			{Version: "20", Label: "OpenJDK 20"},
+			{Version: "17", Label: "OpenJDK 17"},
+			{Version: "11", Label: "OpenJDK 11"},
+			{Version: "8", Label: "OpenJDK 8"},
+		},
+	},
+}
+
+// ListRuntimes returns the runtime catalog.
+func ListRuntimes() []Runtime {
+	return runtimeCatalog
+}
+
+// ResolveRuntime checks that version is available for runtime and returns
+// the pair to build with. An empty version selects the runtime default, an
+// empty or "none" runtime builds a workspace without any toolchain.
+func ResolveRuntime(runtime, version string) (string, string, error) {
+	runtime = strings.ToLower(strings.TrimSpace(runtime))
+	version = strings.TrimSpace(version)
+	if runtime == "" || runtime == "none" {
+		return "none", "", nil
+	}
+
+	for _, r := range runtimeCatalog {
+		if r.ID != runtime {
+			continue
+		}
+		if version == "" {
+			return r.ID, r.Default, nil
+		}
+		for _, v := range r.Versions {
+			if v.Version == version {
+				return r.ID, v.Version, nil
+			}
+		}
+		return "", "", fmt.Errorf("version %s is not available for %s", version, r.Name)
+	}
+	return "", "", fmt.Errorf("unknown runtime: %s", runtime)
+}
+
+// ParseTechnology splits the legacy technology names such as nodelts,
+// node18 or java17 into a runtime and a version.
+func ParseTechnology(technology string) (string, string) {
+	technology = strings.ToLower(strings.TrimSpace(technology))
+	if technology == "nodelts" {
+		return "node", "lts"
+	}
+	for _, r := range runtimeCatalog {
+		if strings.HasPrefix(technology, r.ID) {
+			return r.ID, strings.TrimPrefix(technology, r.ID)
+		}
+	}
+	return technology, ""
+}
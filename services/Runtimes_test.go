@@ -0,0 +1,69 @@
+package services
+
+import "testing"
+
+func TestParseTechnology(t *testing.T) {
+	tests := []struct {
+		technology string
+		runtime    string
+		version    string
+	}{
+		{"nodelts", "node", "lts"},
+		{"node21", "node", "21"},
+		{"node18", "node", "18"},
+		{"NODE18 ", "node", "18"},
+		{"python", "python", ""},
+		{"rust", "rust", ""},
+		{"go", "go", ""},
+		{"java8", "java", "8"},
+		{"java11", "java", "11"},
+		{"java17", "java", "17"},
+		{"java20", "java", "20"},
+		{"java21", "java", "21"},
+		{"none", "none", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		runtime, version := ParseTechnology(tt.technology)
+		if runtime != tt.runtime || version != tt.version {
+			t.Errorf("ParseTechnology(%q) = %q, %q, want %q, %q", tt.technology, runtime, version, tt.runtime, tt.version)
+		}
+	}
+}
+
+// Every technology the original dockerfile could build must still resolve,
+// so that legacy records can be rebuilt.
+func TestParseTechnologyLegacyResolves(t *testing.T) {
+	legacy := []string{"nodelts", "node21", "node18", "python", "rust", "go", "java8", "java11", "java17", "java20", "java21"}
+	for _, technology := range legacy {
+		runtime, version := ParseTechnology(technology)
+		if _, _, err := ResolveRuntime(runtime, version); err != nil {
+			t.Errorf("legacy technology %s does not resolve: %v", technology, err)
+		}
+	}
+}
+
+func TestResolveRuntime(t *testing.T) {
+	tests := []struct {
+		runtime, version         string
+		wantRuntime, wantVersion string
+		wantErr                  bool
+	}{
+		{"node", "", "node", "lts", false},
+		{" Node ", "20", "node", "20", false},
+		{"", "", "none", "", false},
+		{"none", "17", "none", "", false},
+		{"java", "9", "", "", true},
+		{"cobol", "", "", "", true},
+	}
+	for _, tt := range tests {
+		runtime, version, err := ResolveRuntime(tt.runtime, tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveRuntime(%q, %q) error = %v, want error %v", tt.runtime, tt.version, err, tt.wantErr)
+			continue
+		}
+		if runtime != tt.wantRuntime || version != tt.wantVersion {
+			t.Errorf("ResolveRuntime(%q, %q) = %q, %q, want %q, %q", tt.runtime, tt.version, runtime, version, tt.wantRuntime, tt.wantVersion)
+		}
+	}
+}
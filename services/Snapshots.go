@@ -0,0 +1,238 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// Snapshot images carry these labels on top of the labels of the
+// workspace image they were committed from.
+const (
+	labelSnapshot        = "contanize.snapshot"
+	labelSnapshotNote    = "contanize.snapshot.note"
+	labelSnapshotCreated = "contanize.snapshot.created"
+)
+
+// snapshotTimeFormat stamps snapshot tags down to the millisecond.
+const snapshotTimeFormat = "20060102-150405.000"
+
+// Snapshot is a committed image of a workspace container, recorded with
+// the workspace in the store.
+type Snapshot struct {
+	Tag     string `yaml:"tag" json:"tag"`
+	ImageID string `yaml:"image_id" json:"image_id"`
+	Note    string `yaml:"note,omitempty" json:"note"`
+	Created string `yaml:"created" json:"created"`
+}
+
+// CreateSnapshot commits the current state of a workspace container to a
+// new image tagged <workspace image>:snapshot-<timestamp>.
+func (ds *DockerStarter) CreateSnapshot(containerName, note string) (*Snapshot, error) {
+	info, err := ds.cli.ContainerInspect(ds.ctx, containerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %v", err)
+	}
+	if info.Config.Labels["type"] == "Database" {
+		return nil, fmt.Errorf("%s is a database, its data lives in volumes and cannot be snapshotted", containerName)
+	}
+
+	// The store stays unlocked while the container is committed
+	record, err := findRecord(info.ID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := ds.commitSnapshot(info.ID, record.Name, info.Config, note)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addSnapshots(info.ID, info.ID, *snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (ds *DockerStarter) commitSnapshot(containerID, name string, config *container.Config, note string) (*Snapshot, error) {
+	// Tags are unique to the millisecond, a snapshot taken in the same
+	// millisecond as another waits for the next one
+	created := time.Now()
+	tag := fmt.Sprintf("%s:snapshot-%s", imageRepository(config.Image), created.Format(snapshotTimeFormat))
+	for {
+		if _, _, err := ds.cli.ImageInspectWithRaw(ds.ctx, tag); client.IsErrNotFound(err) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to inspect image: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+		created = time.Now()
+		tag = fmt.Sprintf("%s:snapshot-%s", imageRepository(config.Image), created.Format(snapshotTimeFormat))
+	}
+
+	snapshotConfig := *config
+	snapshotConfig.Labels = make(map[string]string)
+	for key, value := range config.Labels {
+		snapshotConfig.Labels[key] = value
+	}
+	snapshotConfig.Labels[labelSnapshot] = name
+	snapshotConfig.Labels[labelSnapshotNote] = note
+	snapshotConfig.Labels[labelSnapshotCreated] = created.Format(time.RFC3339)
+
+	resp, err := ds.cli.ContainerCommit(ds.ctx, containerID, container.CommitOptions{
+		Reference: tag,
+		Comment:   note,
+		Config:    &snapshotConfig,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit container: %v", err)
+	}
+
+	return &Snapshot{
+		Tag:     tag,
+		ImageID: resp.ID,
+		Note:    note,
+		Created: created.Format(time.RFC3339),
+	}, nil
+}
+
+// addSnapshots records snapshots with the store entry of the container
+// oldID, which is moved over to the container newID.
+func addSnapshots(oldID, newID string, snapshots ...Snapshot) error {
+	transaction, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer transaction.rollback()
+
+	record, ok := transaction.ReadEntryById(oldID)
+	if !ok {
+		return fmt.Errorf("container %s has no record in the store", shortID(oldID))
+	}
+	updated := *record
+	updated.ContainerID = newID
+	updated.Snapshots = append(append([]Snapshot{}, record.Snapshots...), snapshots...)
+	transaction.UpdateEntry(record.ContainerID, updated)
+	if err := transaction.commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// ListSnapshots returns the recorded snapshots of a workspace whose image
+// still exists, oldest first.
+func (ds *DockerStarter) ListSnapshots(containerName string) ([]Snapshot, error) {
+	info, err := ds.cli.ContainerInspect(ds.ctx, containerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %v", err)
+	}
+
+	transaction, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := transaction.ReadEntryById(info.ID)
+	transaction.rollback()
+	if !ok {
+		return nil, nil
+	}
+
+	var snapshots []Snapshot
+	for _, snapshot := range record.Snapshots {
+		if _, _, err := ds.cli.ImageInspectWithRaw(ds.ctx, snapshot.ImageID); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// RestoreSnapshot recreates a workspace container from one of its
+// snapshots, keeping its mounts and ports. The current state is snapshotted
+// first so that a restore can itself be undone. It returns the snapshot of
+// the replaced state.
+func (ds *DockerStarter) RestoreSnapshot(containerName, tag string) (*Snapshot, error) {
+	info, err := ds.cli.ContainerInspect(ds.ctx, containerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %v", err)
+	}
+
+	record, err := findRecord(info.ID)
+	if err != nil {
+		return nil, err
+	}
+	var target *Snapshot
+	for i := range record.Snapshots {
+		if record.Snapshots[i].Tag == tag {
+			target = &record.Snapshots[i]
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%s has no snapshot %s", containerName, tag)
+	}
+
+	previous, err := ds.commitSnapshot(info.ID, record.Name, info.Config, fmt.Sprintf("Before restoring %s", tag))
+	if err != nil {
+		return nil, err
+	}
+
+	// The container keeps running its workspace image, which now points at
+	// the snapshot. Later restarts commit onto the workspace image and
+	// leave the snapshot untouched.
+	if err := ds.cli.ImageTag(ds.ctx, target.ImageID, info.Config.Image); err != nil {
+		return nil, fmt.Errorf("failed to tag snapshot: %v", err)
+	}
+
+	containerID, err := ds.RecreateContainer(info, info.Config.Image, info.HostConfig.PortBindings)
+	if err != nil {
+		// The old container is back, so is the image its tag pointed at
+		if tagErr := ds.cli.ImageTag(ds.ctx, info.Image, info.Config.Image); tagErr != nil {
+			log.Printf("Failed to tag %s back: %v", info.Config.Image, tagErr)
+		}
+		err = fmt.Errorf("failed to restore snapshot: %v", err)
+		if recordErr := addSnapshots(info.ID, info.ID, *previous); recordErr != nil {
+			log.Printf("Failed to record snapshot %s: %v", previous.Tag, recordErr)
+		}
+		return nil, err
+	}
+
+	if err := addSnapshots(info.ID, containerID, *previous); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// imageRepository strips the tag from an image reference.
+func imageRepository(ref string) string {
+	if hasTag(ref) {
+		return ref[:strings.LastIndex(ref, ":")]
+	}
+	return ref
+}
+
+func CreateSnapshot(containerName, note string) (*Snapshot, error) {
+	ds, err := NewDockerStarter()
+	if err != nil {
+		return nil, err
+	}
+	return ds.CreateSnapshot(containerName, note)
+}
+
+func ListSnapshots(containerName string) ([]Snapshot, error) {
+	ds, err := NewDockerStarter()
+	if err != nil {
+		return nil, err
+	}
+	return ds.ListSnapshots(containerName)
+}
+
+func RestoreSnapshot(containerName, tag string) (*Snapshot, error) {
+	ds, err := NewDockerStarter()
+	if err != nil {
+		return nil, err
+	}
+	return ds.RestoreSnapshot(containerName, tag)
+}
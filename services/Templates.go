@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a project template manifest. Adding a manifest to the
+// templates directory of the build context, or of the build override
+// directory, is enough to make a new template available.
+type Template struct {
+	Name        string   `yaml:"name" json:"name"`
+	Title       string   `yaml:"title" json:"title"`
+	Description string   `yaml:"description" json:"description"`
+	Group       string   `yaml:"group" json:"group"`
+	Technology  string   `yaml:"technology" json:"technology"`
+	Version     string   `yaml:"version" json:"version"`
+	Ports       []string `yaml:"ports" json:"ports"`
+	Scaffold    []string `yaml:"scaffold" json:"scaffold"`
+}
+
+// DefaultPorts returns the manifest ports in the comma separated form
+// used by CreateOptions.
+func (t *Template) DefaultPorts() string {
+	return strings.Join(t.Ports, ",")
+}
+
+// ScaffoldScript joins the scaffold commands into a script run by setup.sh.
+func (t *Template) ScaffoldScript() string {
+	return strings.Join(t.Scaffold, "\n")
+}
+
+// ListTemplates loads every manifest in the templates directory of the
+// build context, sorted by group and name. Malformed manifests, typically
+// from the override directory, are logged and skipped.
+func ListTemplates() ([]Template, error) {
+	files, err := buildContextGlob("templates/*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %v", err)
+	}
+
+	var templates []Template
+	for filename, data := range files {
+		template, err := readTemplate(filename, data)
+		if err != nil {
+			log.Printf("Skipping template: %v", err)
+			continue
+		}
+		templates = append(templates, *template)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Group != templates[j].Group {
+			return templates[i].Group < templates[j].Group
+		}
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// FindTemplate returns the manifest of the template called name.
+func FindTemplate(name string) (*Template, error) {
+	templates, err := ListTemplates()
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates {
+		if template.Name == name {
+			return &template, nil
+		}
+	}
+	return nil, fmt.Errorf("template %s not found", name)
+}
+
+func readTemplate(filename string, data []byte) (*Template, error) {
+	var template Template
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("error unmarshaling template %s: %v", filename, err)
+	}
+
+	if template.Name == "" {
+		template.Name = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	}
+	if template.Title == "" {
+		template.Title = template.Name
+	}
+	if template.Technology == "" {
+		return nil, fmt.Errorf("template %s does not declare a technology", template.Name)
+	}
+	return &template, nil
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestListTemplatesSkipsMalformed(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	saved := embeddedContext
+	t.Cleanup(func() { embeddedContext = saved })
+	SetBuildContext(fstest.MapFS{
+		"templates/react.yaml": {Data: []byte("title: React\ngroup: Web\ntechnology: nodelts\n")},
+	})
+
+	overrideDir, err := BuildOverrideDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	templatesDir := filepath.Join(overrideDir, "templates")
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	overrides := map[string]string{
+		"flask.yaml":  "title: Flask\ngroup: Web\ntechnology: python\n",
+		"broken.yaml": "title: [unterminated\n",
+		"bare.yaml":   "title: No technology\n",
+	}
+	for name, data := range overrides {
+		if err := os.WriteFile(filepath.Join(templatesDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates, err := ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates() error = %v", err)
+	}
+	var names []string
+	for _, template := range templates {
+		names = append(names, template.Name)
+	}
+	if len(names) != 2 || names[0] != "flask" || names[1] != "react" {
+		t.Errorf("ListTemplates() = %v, want [flask react]", names)
+	}
+}
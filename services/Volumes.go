@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// labelVolumeDatabase names the database container a volume was created
+// for.
+const labelVolumeDatabase = "contanize.database"
+
+// ErrVolumeExists is returned when the volume a database would be created
+// with already exists, typically left behind by a removed database of the
+// same name.
+var ErrVolumeExists = errors.New("volume already exists")
+
+// Volume is a named volume created by Contanize.
+type Volume struct {
+	Name     string `json:"name"`
+	Database string `json:"database"`
+	Engine   string `json:"engine"`
+	Created  string `json:"created"`
+	// Size is in bytes, -1 when Docker does not report it.
+	Size int64 `json:"size"`
+	// InUse tells whether a container, running or not, mounts the volume.
+	InUse bool `json:"in_use"`
+}
+
+// databaseVolumeName returns the name of the volume holding the data of
+// the database container containerName.
+func databaseVolumeName(containerName string) string {
+	return fmt.Sprintf("contanize-%s-data", containerName)
+}
+
+// ListVolumes returns the volumes created by Contanize, sorted by name.
+func ListVolumes(ctx context.Context) ([]Volume, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	// Unlike the volume list, disk usage reports sizes and reference counts
+	usage, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %v", err)
+	}
+
+	volumes := []Volume{}
+	for _, v := range usage.Volumes {
+		if v.Labels["createdBy"] != "Contanize" {
+			continue
+		}
+		volume := Volume{
+			Name:     v.Name,
+			Database: v.Labels[labelVolumeDatabase],
+			Engine:   v.Labels["db"],
+			Created:  v.CreatedAt,
+			Size:     -1,
+		}
+		if v.UsageData != nil {
+			volume.Size = v.UsageData.Size
+			volume.InUse = v.UsageData.RefCount > 0
+		}
+		volumes = append(volumes, volume)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes, nil
+}
+
+// RemoveVolume removes a volume created by Contanize. Volumes still
+// mounted by a container are refused by Docker.
+func RemoveVolume(ctx context.Context, name string) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	v, err := cli.VolumeInspect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to inspect volume: %v", err)
+	}
+	if v.Labels["createdBy"] != "Contanize" {
+		return fmt.Errorf("volume %s was not created by Contanize", name)
+	}
+	if err := cli.VolumeRemove(ctx, name, false); err != nil {
+		return fmt.Errorf("failed to remove volume: %v", err)
+	}
+	return nil
+}